@@ -3,12 +3,14 @@ module github.com/anz-bank/golden-retriever
 go 1.21
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/go-git/go-billy/v5 v5.5.1-0.20240427054813-8453aa90c6ec
 	github.com/go-git/go-git/v5 v5.12.1-0.20240729070005-9debed20a895
 	github.com/sirupsen/logrus v1.9.3
 	github.com/spf13/afero v1.11.0
 	github.com/stretchr/testify v1.9.0
 	github.com/undefinedlabs/go-mpatch v1.0.7
+	golang.org/x/mod v0.17.0
 	golang.org/x/net v0.27.0
 	gopkg.in/yaml.v3 v3.0.1
 )
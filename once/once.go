@@ -29,6 +29,14 @@ func (o Once) Register(k string) chan bool {
 	return nil
 }
 
+// Wait registers the key, first waiting for every earlier registration of the key to be unregistered.
+// Unlike Register, at most one caller holds the registration of a key at a time.
+func (o Once) Wait(k string) {
+	for c := o.Register(k); c != nil; c = o.Register(k) {
+		<-c
+	}
+}
+
 func (o Once) Unregister(k string) {
 	o.mutex.Lock()
 	defer o.mutex.Unlock()
@@ -0,0 +1,79 @@
+package pinner
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Encoding marshals and unmarshals the content of a mod file.
+type Encoding interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(b []byte, v interface{}) error
+}
+
+var (
+	encodings = map[string]Encoding{
+		".yaml": YAMLEncoding{},
+		".yml":  YAMLEncoding{},
+		".json": JSONEncoding{},
+		".toml": TOMLEncoding{},
+	}
+	encodingsMutex sync.RWMutex
+)
+
+// RegisterEncoding registers the encoding used for mod files with the given extension, e.g. ".json".
+func RegisterEncoding(ext string, e Encoding) {
+	encodingsMutex.Lock()
+	defer encodingsMutex.Unlock()
+	encodings[strings.ToLower(ext)] = e
+}
+
+// EncodingFor returns the encoding of the given mod file, selected by its extension.
+// YAML is used when the extension is unknown.
+func EncodingFor(modFile string) Encoding {
+	encodingsMutex.RLock()
+	defer encodingsMutex.RUnlock()
+	if e, ok := encodings[strings.ToLower(filepath.Ext(modFile))]; ok {
+		return e
+	}
+	return YAMLEncoding{}
+}
+
+// YAMLEncoding implements the Encoding interface for YAML mod files.
+type YAMLEncoding struct{}
+
+func (YAMLEncoding) Marshal(v interface{}) ([]byte, error) { return yaml.Marshal(v) }
+
+func (YAMLEncoding) Unmarshal(b []byte, v interface{}) error { return yaml.Unmarshal(b, v) }
+
+// JSONEncoding implements the Encoding interface for JSON mod files.
+type JSONEncoding struct{}
+
+func (JSONEncoding) Marshal(v interface{}) ([]byte, error) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+func (JSONEncoding) Unmarshal(b []byte, v interface{}) error { return json.Unmarshal(b, v) }
+
+// TOMLEncoding implements the Encoding interface for TOML mod files.
+type TOMLEncoding struct{}
+
+func (TOMLEncoding) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (TOMLEncoding) Unmarshal(b []byte, v interface{}) error { return toml.Unmarshal(b, v) }
@@ -1,31 +1,58 @@
 package pinner
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 
 	log "github.com/sirupsen/logrus"
-	"gopkg.in/yaml.v3"
 )
 
+// ModVersion is the current version of the mod file schema.
+const ModVersion = 1
+
 // Mod is the struct of the file which stores the dependency requirements
 type Mod struct {
-	Imports map[string]*Import `yaml:"imports"`
-	modFile string
-	mutex   sync.RWMutex
+	Version  int                 `yaml:"version" json:"version" toml:"version"`
+	Imports  map[string]*Import  `yaml:"imports" json:"imports" toml:"imports"`
+	Replaces map[string]*Replace `yaml:"replace,omitempty" json:"replace,omitempty" toml:"replace,omitempty"`
+	modFile  string
+	mutex    sync.RWMutex
 }
 
 // Import is the dependency requirement with specified reference and pinned version
 type Import struct {
-	Ref    string `yaml:"ref,omitempty"`
-	Pinned string `yaml:"pinned"`
+	Ref    string `yaml:"ref,omitempty" json:"ref,omitempty" toml:"ref,omitempty"`
+	Pinned string `yaml:"pinned" json:"pinned" toml:"pinned"`
+}
+
+// Replace redirects a repository to another repository (e.g. a fork) or to a local directory,
+// optionally at a different reference. Local directories start with ./, ../ or are absolute, and
+// relative directories are resolved against the directory of the mod file.
+type Replace struct {
+	Repo string `yaml:"repo" json:"repo" toml:"repo"`
+	Ref  string `yaml:"ref,omitempty" json:"ref,omitempty" toml:"ref,omitempty"`
 }
 
-// NewMod initializes and returns a new Mod instance
+// IsLocal reports whether the replacement is a local directory.
+func (r *Replace) IsLocal() bool {
+	return filepath.IsAbs(r.Repo) || r.Repo == "." || r.Repo == ".." ||
+		strings.HasPrefix(r.Repo, "./") || strings.HasPrefix(r.Repo, "../")
+}
+
+// NewMod initializes and returns a new Mod instance.
+// The encoding of the mod file is selected by its extension, see EncodingFor.
 func NewMod(modFile string) (*Mod, error) {
-	m := &Mod{modFile: modFile, mutex: sync.RWMutex{}, Imports: make(map[string]*Import)}
+	m := &Mod{
+		Version:  ModVersion,
+		modFile:  modFile,
+		mutex:    sync.RWMutex{},
+		Imports:  make(map[string]*Import),
+		Replaces: make(map[string]*Replace),
+	}
 
 	if _, err := os.Stat(modFile); err == nil {
 		b, err := ioutil.ReadFile(modFile)
@@ -33,14 +60,37 @@ func NewMod(modFile string) (*Mod, error) {
 			return nil, err
 		}
 
-		if err := yaml.Unmarshal(b, m); err != nil {
+		m.Version = 0
+		if err := EncodingFor(modFile).Unmarshal(b, m); err != nil {
 			return nil, err
 		}
+		if err := m.migrate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", modFile, err)
+		}
 	}
 
 	return m, nil
 }
 
+// migrate upgrades the content of a mod file read from disk to the current schema version.
+func (m *Mod) migrate() error {
+	switch {
+	case m.Version > ModVersion:
+		return fmt.Errorf("unsupported mod file version %d, the latest supported version is %d", m.Version, ModVersion)
+	case m.Version == 0:
+		// The unversioned layout only contains imports, which are unchanged in version 1.
+		log.Debugf("migrating %s from the unversioned layout to version %d", m.modFile, ModVersion)
+	}
+	if m.Imports == nil {
+		m.Imports = make(map[string]*Import)
+	}
+	if m.Replaces == nil {
+		m.Replaces = make(map[string]*Replace)
+	}
+	m.Version = ModVersion
+	return nil
+}
+
 // GetImport returns Import with given repository key
 func (m *Mod) GetImport(repo string) (*Import, bool) {
 	m.mutex.RLock()
@@ -61,12 +111,43 @@ func (m *Mod) SetImport(repo string, im *Import) {
 	return
 }
 
+// GetReplace returns the Replace of the given repository key
+func (m *Mod) GetReplace(repo string) (*Replace, bool) {
+	m.mutex.RLock()
+	r, ok := m.Replaces[repo]
+	m.mutex.RUnlock()
+	return r, ok
+}
+
+// SetReplace sets value Replace with given repository key, a nil value removes the replacement
+func (m *Mod) SetReplace(repo string, r *Replace) {
+	m.mutex.Lock()
+	if r == nil {
+		delete(m.Replaces, repo)
+	} else {
+		m.Replaces[repo] = r
+	}
+	m.mutex.Unlock()
+}
+
+// ReplaceDir returns the local directory of the given replacement, resolved against the directory of the mod file.
+func (m *Mod) ReplaceDir(r *Replace) string {
+	if filepath.IsAbs(r.Repo) {
+		return filepath.Clean(r.Repo)
+	}
+	dir, err := filepath.Abs(filepath.Join(filepath.Dir(m.modFile), r.Repo))
+	if err != nil {
+		return filepath.Join(filepath.Dir(m.modFile), r.Repo)
+	}
+	return dir
+}
+
 // Save Mod content to modFile
 func (m *Mod) Save() error {
 	_, err := os.Stat(m.modFile)
 
 	if os.IsNotExist(err) {
-		if len(m.Imports) == 0 {
+		if len(m.Imports) == 0 && len(m.Replaces) == 0 {
 			return nil
 		}
 
@@ -87,7 +168,7 @@ func (m *Mod) Save() error {
 
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
-	b, err := yaml.Marshal(m)
+	b, err := EncodingFor(m.modFile).Marshal(m)
 	if err != nil {
 		return err
 	}
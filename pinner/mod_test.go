@@ -0,0 +1,69 @@
+package pinner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestModEncodings(t *testing.T) {
+	tests := []struct {
+		modFile string
+		content string
+	}{
+		{"modules.yaml", "version: 1\nimports:\n    github.com/foo/bar:\n        ref: main\n        pinned: 133416d690dbffc8fe321e12bdd4f21d79e2a479\n"},
+		{"modules.json", "{\n  \"version\": 1,\n  \"imports\": {\n    \"github.com/foo/bar\": {\n      \"ref\": \"main\",\n      \"pinned\": \"133416d690dbffc8fe321e12bdd4f21d79e2a479\"\n    }\n  }\n}\n"},
+		{"modules.toml", "version = 1\n\n[imports]\n  [imports.\"github.com/foo/bar\"]\n    ref = \"main\"\n    pinned = \"133416d690dbffc8fe321e12bdd4f21d79e2a479\"\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.modFile, func(t *testing.T) {
+			modFile := filepath.Join(t.TempDir(), test.modFile)
+			m, err := NewMod(modFile)
+			require.NoError(t, err)
+
+			m.SetImport("github.com/foo/bar", &Import{Ref: "main", Pinned: "133416d690dbffc8fe321e12bdd4f21d79e2a479"})
+			require.NoError(t, m.Save())
+
+			b, err := os.ReadFile(modFile)
+			require.NoError(t, err)
+			require.Equal(t, test.content, string(b))
+
+			m, err = NewMod(modFile)
+			require.NoError(t, err)
+			require.Equal(t, ModVersion, m.Version)
+			im, ok := m.GetImport("github.com/foo/bar")
+			require.True(t, ok)
+			require.Equal(t, &Import{Ref: "main", Pinned: "133416d690dbffc8fe321e12bdd4f21d79e2a479"}, im)
+		})
+	}
+}
+
+func TestModMigrateUnversioned(t *testing.T) {
+	modFile := filepath.Join(t.TempDir(), "modules.yaml")
+	err := os.WriteFile(modFile, []byte("imports:\n    github.com/foo/bar:\n        pinned: 133416d690dbffc8fe321e12bdd4f21d79e2a479\n"), 0644)
+	require.NoError(t, err)
+
+	m, err := NewMod(modFile)
+	require.NoError(t, err)
+	require.Equal(t, ModVersion, m.Version)
+	im, ok := m.GetImport("github.com/foo/bar")
+	require.True(t, ok)
+	require.Equal(t, "133416d690dbffc8fe321e12bdd4f21d79e2a479", im.Pinned)
+
+	require.NoError(t, m.Save())
+	b, err := os.ReadFile(modFile)
+	require.NoError(t, err)
+	require.Equal(t, "version: 1\nimports:\n    github.com/foo/bar:\n        pinned: 133416d690dbffc8fe321e12bdd4f21d79e2a479\n", string(b))
+}
+
+func TestModUnsupportedVersion(t *testing.T) {
+	modFile := filepath.Join(t.TempDir(), "modules.json")
+	err := os.WriteFile(modFile, []byte(`{"version": 99, "imports": {}}`), 0644)
+	require.NoError(t, err)
+
+	_, err = NewMod(modFile)
+	require.ErrorContains(t, err, "unsupported mod file version 99")
+}
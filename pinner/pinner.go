@@ -4,16 +4,24 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
 
 	"github.com/anz-bank/golden-retriever/retriever"
+	log "github.com/sirupsen/logrus"
 )
 
 // Pinner is an implementation of Retriever interface with ability to pin git repository version
 type Pinner struct {
 	mod       *Mod
 	retriever retriever.Retriever
+	tagPolicy retriever.TagPolicy
+	checked   sync.Map // The repositories whose pinned tags have been checked.
 }
 
+var _ retriever.InfoRetriever = &Pinner{}
+
 // New intializes and returns new Pinner instance
 func New(modFile string, retriever retriever.Retriever) (*Pinner, error) {
 	if retriever == nil {
@@ -31,9 +39,32 @@ func New(modFile string, retriever retriever.Retriever) (*Pinner, error) {
 	}, nil
 }
 
+// WithTagPolicy checks the tags pinned in the mod file against the remote repository (if the wrapped retriever is a
+// retriever.TagResolver) as per the policy, returning the pinner. Moved tags are pinned to their new commit if accepted.
+func (m *Pinner) WithTagPolicy(policy retriever.TagPolicy) *Pinner {
+	m.tagPolicy = policy
+	return m
+}
+
 // Retrieve returns the bytes of the given resource.
 // If no reference specified and the repository has been retrieved and pinned before, the pinned one will be returned.
-func (m *Pinner) Retrieve(ctx context.Context, resource *retriever.Resource) (content []byte, err error) {
+// If the repository is replaced in the mod file, the resource is retrieved from its replacement instead.
+func (m *Pinner) Retrieve(ctx context.Context, resource *retriever.Resource) ([]byte, error) {
+	result, err := m.RetrieveWithInfo(ctx, resource)
+	if result == nil {
+		return nil, err
+	}
+	return result.Content, err // The content is returned even if the mod file couldn't be saved.
+}
+
+// RetrieveWithInfo retrieves the given resource as Retrieve does, returning its content with information about where
+// it came from (as far as is known by the wrapped retriever). The resource isn't modified.
+func (m *Pinner) RetrieveWithInfo(ctx context.Context, resource *retriever.Resource) (result *retriever.Result, err error) {
+	resource = resource.Clone()
+	if rep, ok := m.mod.GetReplace(resource.Repo); ok {
+		return m.retrieveReplaced(ctx, resource, rep)
+	}
+
 	onlyHash := (resource.Ref != nil && resource.Ref.IsHash() && resource.Ref.Name() == "")
 	i, ok := m.mod.GetImport(resource.Repo)
 	if ok && !onlyHash {
@@ -43,6 +74,9 @@ func (m *Pinner) Retrieve(ctx context.Context, resource *retriever.Resource) (co
 			if err != nil {
 				return nil, err
 			}
+			if h, err = m.checkTag(ctx, resource.Repo, i, h); err != nil {
+				return nil, err
+			}
 			r, err := retriever.NewReference(i.Ref, h)
 			if err != nil {
 				return nil, fmt.Errorf("Module ref %s and pinned %s error: %s", i.Ref, i.Pinned, err.Error())
@@ -55,15 +89,15 @@ func (m *Pinner) Retrieve(ctx context.Context, resource *retriever.Resource) (co
 		}
 	}
 
-	content, err = m.retriever.Retrieve(ctx, resource)
+	result, err = retriever.RetrieveWithInfo(ctx, m.retriever, resource)
 	if err != nil {
 		return nil, err
 	}
 
 	if !ok && !onlyHash {
-		im := &Import{Pinned: resource.Ref.Hash().String()}
-		if resource.Ref.Name() != "" && resource.Ref.Name() != retriever.HEAD {
-			im.Ref = resource.Ref.Name()
+		im := &Import{Pinned: result.Hash.String()}
+		if result.Ref != "" && result.Ref != retriever.HEAD {
+			im.Ref = result.Ref
 		}
 		m.mod.SetImport(resource.Repo, im)
 		err = m.mod.Save()
@@ -72,6 +106,126 @@ func (m *Pinner) Retrieve(ctx context.Context, resource *retriever.Resource) (co
 	return
 }
 
+// checkTag checks the pinned reference of the import of the repository against the remote repository as per the tag
+// policy if it is a tag (once per repository, unless it fails), returning the commit to retrieve. The tag is pinned to
+// its new commit if the move is accepted. Tags can't be checked while the retriever is offline, so the pinned commit
+// is retrieved.
+func (m *Pinner) checkTag(ctx context.Context, repo string, i *Import, pinned retriever.Hash) (retriever.Hash, error) {
+	tr, ok := m.retriever.(retriever.TagResolver)
+	if m.tagPolicy == retriever.TagPolicyTrust || i.Ref == "" || !ok {
+		return pinned, nil
+	}
+	if _, checked := m.checked.Load(repo); checked {
+		return pinned, nil
+	}
+	h, isTag, err := tr.ResolveRemoteTag(ctx, repo, i.Ref)
+	if errors.Is(err, retriever.ErrOffline) {
+		log.Debugf("pinned tag %s of %s not checked: %s", i.Ref, repo, err)
+		return pinned, nil
+	} else if err != nil {
+		return pinned, fmt.Errorf("error checking pinned tag %s of %s: %w", i.Ref, repo, err)
+	}
+	if !isTag || h == pinned {
+		m.checked.Store(repo, true)
+		return pinned, nil
+	}
+
+	moved := &retriever.TagMovedError{Repo: repo, Tag: i.Ref, Old: pinned, New: h}
+	switch m.tagPolicy {
+	case retriever.TagPolicyWarn:
+		log.Warnf("%s, retrieving pinned %s", moved, pinned)
+	case retriever.TagPolicyAccept:
+		log.Infof("%s, pinning %s", moved, h)
+		m.mod.SetImport(repo, &Import{Ref: i.Ref, Pinned: h.String()})
+		if err := m.mod.Save(); err != nil {
+			return pinned, err
+		}
+		pinned = h
+	default:
+		return pinned, moved
+	}
+	m.checked.Store(repo, true)
+	return pinned, nil
+}
+
+// localRetriever is implemented by retrievers (e.g. git.Git) reporting whether they may retrieve local repositories.
+type localRetriever interface {
+	Local() bool
+}
+
+// retrieveReplaced retrieves the resource from the given replacement. Local directories without a reference are read
+// from their working tree (refusing files outside of it), otherwise the replacement is retrieved by the wrapped
+// retriever. Local directories are only replaced if the wrapped retriever may retrieve local repositories (e.g. is
+// configured with the Local authenticator). Replaced repositories are never pinned.
+func (m *Pinner) retrieveReplaced(ctx context.Context, resource *retriever.Resource, rep *Replace) (*retriever.Result, error) {
+	ref := resource.Ref
+	if rep.Ref != "" {
+		if h, err := retriever.NewHash(rep.Ref); err == nil {
+			ref, _ = retriever.NewHashReference(h)
+		} else {
+			ref = retriever.NewSymbolicReference(rep.Ref)
+		}
+	}
+	if ref == nil {
+		ref = retriever.HEADReference()
+	}
+
+	repo := rep.Repo
+	if rep.IsLocal() {
+		repo = m.mod.ReplaceDir(rep)
+		if l, ok := m.retriever.(localRetriever); ok && !l.Local() {
+			return nil, fmt.Errorf("error retrieving %s from local replacement %s: local repositories require the Local authenticator", resource, repo)
+		}
+		if rep.Ref == "" {
+			log.Debugf("reading %s from the working tree of replacement %s", resource.Filepath, repo)
+			path, err := workingTreePath(repo, resource.Filepath)
+			if err != nil {
+				return nil, err
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+			return &retriever.Result{Content: content, Mode: info.Mode(), Size: info.Size(), Remote: repo}, nil
+		}
+	}
+
+	log.Debugf("retrieving %s from replacement %s@%s", resource, repo, ref)
+	replaced := &retriever.Resource{Repo: repo, Filepath: resource.Filepath, Ref: ref}
+	return retriever.RetrieveWithInfo(ctx, m.retriever, replaced)
+}
+
+// workingTreePath returns the path of the file within the working tree of the directory, following symlinks, or an
+// error if it is outside of the directory.
+func workingTreePath(dir, file string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(file)) {
+		return "", fmt.Errorf("file %s is outside of local replacement %s", file, dir)
+	}
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(file)))
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(root, path); err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("file %s is outside of local replacement %s", file, dir)
+	}
+	return path, nil
+}
+
+// Replace redirects the repository to the given replacement and saves it to the mod file.
+// A nil replacement removes any existing replacement of the repository.
+func (m *Pinner) Replace(repo string, with *Replace) error {
+	m.mod.SetReplace(repo, with)
+	return m.mod.Save()
+}
+
 func (m *Pinner) Unpin(repos []string) error {
 	for _, repo := range repos {
 		m.mod.SetImport(repo, nil)
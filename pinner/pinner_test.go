@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/anz-bank/golden-retriever/retriever"
@@ -60,10 +61,11 @@ func TestPinnerRetrieve(t *testing.T) {
 				Filepath: "baz.md",
 				Ref:      ref,
 			}
-			c, err := pinner.Retrieve(context.Background(), resource)
+			result, err := pinner.RetrieveWithInfo(context.Background(), resource)
 			require.NoError(t, err)
-			require.Equal(t, test.content, c)
-			require.Equal(t, test.hash, resource.Ref.Hash())
+			require.Equal(t, test.content, result.Content)
+			require.Equal(t, test.hash, result.Hash)
+			require.Equal(t, test.refhash, resource.Ref.Hash(), "the resource is not modified")
 
 			if test.refhash.IsZero() {
 				err = os.Remove(modFile)
@@ -104,13 +106,14 @@ func TestPinnerRetrieveModFile(t *testing.T) {
 		Filepath: "baz.md",
 		Ref:      nil,
 	}
-	_, err = pinner.Retrieve(context.Background(), resource)
+	result, err := pinner.RetrieveWithInfo(context.Background(), resource)
 	require.NoError(t, err)
-	require.Equal(t, retr.HEADHash(), resource.Ref.Hash())
-	require.Equal(t, "master", resource.Ref.Name())
+	require.Equal(t, retr.HEADHash(), result.Hash)
+	require.Equal(t, "master", result.Ref)
+	require.Nil(t, resource.Ref)
 	b, err := ioutil.ReadFile(modFile)
 	require.NoError(t, err)
-	require.Equal(t, fmt.Sprintf("imports:\n    github.com/foo/bar:\n        ref: %s\n        pinned: %s\n", "master", retr.HEADHash()), string(b))
+	require.Equal(t, fmt.Sprintf("version: 1\nimports:\n    github.com/foo/bar:\n        ref: %s\n        pinned: %s\n", "master", retr.HEADHash()), string(b))
 
 	ref, err := retriever.NewReference("v1", retriever.ZeroHash)
 	require.NoError(t, err)
@@ -123,7 +126,7 @@ func TestPinnerRetrieveModFile(t *testing.T) {
 	require.EqualError(t, err, "cannot import multiple versions (v1, master) of a single repo github.com/foo/bar")
 	b, err = ioutil.ReadFile(modFile)
 	require.NoError(t, err)
-	require.Equal(t, fmt.Sprintf("imports:\n    github.com/foo/bar:\n        ref: %s\n        pinned: %s\n", "master", retr.HEADHash()), string(b))
+	require.Equal(t, fmt.Sprintf("version: 1\nimports:\n    github.com/foo/bar:\n        ref: %s\n        pinned: %s\n", "master", retr.HEADHash()), string(b))
 
 	for _, test := range tests {
 		s := test.refhash.String()
@@ -139,14 +142,218 @@ func TestPinnerRetrieveModFile(t *testing.T) {
 				Filepath: "baz.md",
 				Ref:      ref,
 			}
-			_, err = pinner.Retrieve(context.Background(), resource)
+			result, err = pinner.RetrieveWithInfo(context.Background(), resource)
+			require.NoError(t, err)
+			require.Equal(t, test.name, result.Ref)
+			require.Equal(t, test.hash, result.Hash)
+			unmodified, err := retriever.NewReference(test.refname, test.refhash)
 			require.NoError(t, err)
-			require.Equal(t, test.name, resource.Ref.Name())
-			require.Equal(t, test.hash, resource.Ref.Hash())
+			require.Equal(t, unmodified, resource.Ref)
 
 			b, err = ioutil.ReadFile(modFile)
 			require.NoError(t, err)
-			require.Equal(t, fmt.Sprintf("imports:\n    github.com/foo/bar:\n        ref: %s\n        pinned: %s\n", "master", retr.HEADHash()), string(b))
+			require.Equal(t, fmt.Sprintf("version: 1\nimports:\n    github.com/foo/bar:\n        ref: %s\n        pinned: %s\n", "master", retr.HEADHash()), string(b))
 		})
 	}
 }
+
+// repoRecorder records the repositories retrieved through it.
+type repoRecorder struct {
+	mock.Retriever
+	repos []string
+}
+
+func (r *repoRecorder) Retrieve(ctx context.Context, resource *retriever.Resource) ([]byte, error) {
+	r.repos = append(r.repos, resource.Repo)
+	return r.Retriever.Retrieve(ctx, resource)
+}
+
+func (r *repoRecorder) RetrieveWithInfo(ctx context.Context, resource *retriever.Resource) (*retriever.Result, error) {
+	r.repos = append(r.repos, resource.Repo)
+	return r.Retriever.RetrieveWithInfo(ctx, resource)
+}
+
+func TestPinnerRetrieveReplace(t *testing.T) {
+	tmp := t.TempDir()
+	modFile := filepath.Join(tmp, "modules.yaml")
+	err := os.MkdirAll(filepath.Join(tmp, "specs", "api"), os.ModePerm)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(tmp, "specs", "api", "spec.yaml"), []byte("local content"), 0644)
+	require.NoError(t, err)
+
+	retr := &repoRecorder{}
+	pinner, err := New(modFile, retr)
+	require.NoError(t, err)
+	require.NoError(t, pinner.Replace("github.com/org/fork", &Replace{Repo: "github.com/me/fork", Ref: "v1"}))
+	require.NoError(t, pinner.Replace("github.com/org/specs", &Replace{Repo: "../" + filepath.Base(tmp) + "/specs"}))
+
+	// Remote replacements rewrite the repository and reference.
+	resource := &retriever.Resource{Repo: "github.com/org/fork", Filepath: "baz.md", Ref: retriever.NewSymbolicReference("master")}
+	result, err := pinner.RetrieveWithInfo(context.Background(), resource)
+	require.NoError(t, err)
+	require.Equal(t, retr.TagContent(), result.Content)
+	require.Equal(t, []string{"github.com/me/fork"}, retr.repos)
+	require.Equal(t, retr.TagHash(), result.Hash)
+	require.Equal(t, "master", resource.Ref.Name())
+
+	// Local replacements without a reference are read from the working tree.
+	resource = &retriever.Resource{Repo: "github.com/org/specs", Filepath: "api/spec.yaml", Ref: retriever.HEADReference()}
+	c, err := pinner.Retrieve(context.Background(), resource)
+	require.NoError(t, err)
+	require.Equal(t, "local content", string(c))
+	require.Len(t, retr.repos, 1)
+
+	// Replaced repositories are not pinned.
+	b, err := os.ReadFile(modFile)
+	require.NoError(t, err)
+	require.NotContains(t, string(b), "imports:\n    github.com")
+	require.Contains(t, string(b), "replace:\n")
+
+	// Removing the replacement restores retrieval from the original repository.
+	require.NoError(t, pinner.Replace("github.com/org/fork", nil))
+	resource = &retriever.Resource{Repo: "github.com/org/fork", Filepath: "baz.md", Ref: retriever.NewSymbolicReference("master")}
+	c, err = pinner.Retrieve(context.Background(), resource)
+	require.NoError(t, err)
+	require.Equal(t, retr.BranchContent(), c)
+	require.Equal(t, []string{"github.com/me/fork", "github.com/org/fork"}, retr.repos)
+}
+
+// localRecorder is a repoRecorder that may or may not retrieve local repositories.
+type localRecorder struct {
+	repoRecorder
+	local bool
+}
+
+func (r *localRecorder) Local() bool { return r.local }
+
+func TestPinnerRetrieveReplace_Local(t *testing.T) {
+	tmp := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tmp, "specs"), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(tmp, "specs", "spec.yaml"), []byte("local content"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tmp, "secret"), []byte("secret"), 0644))
+	require.NoError(t, os.Symlink(filepath.Join(tmp, "secret"), filepath.Join(tmp, "specs", "link")))
+
+	retr := &localRecorder{local: true}
+	pinner, err := New(filepath.Join(tmp, "modules.yaml"), retr)
+	require.NoError(t, err)
+	require.NoError(t, pinner.Replace("github.com/org/specs", &Replace{Repo: "./specs"}))
+	retrieve := func(file string) ([]byte, error) {
+		return pinner.Retrieve(context.Background(), &retriever.Resource{Repo: "github.com/org/specs", Filepath: file})
+	}
+
+	c, err := retrieve("spec.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "local content", string(c))
+
+	// Files outside of the working tree are refused.
+	for _, file := range []string{"../secret", "../../etc/passwd", "/etc/passwd", "link"} {
+		_, err = retrieve(file)
+		require.ErrorContains(t, err, "outside of local replacement", file)
+	}
+
+	// Local directories are only replaced if the retriever may retrieve local repositories.
+	retr.local = false
+	_, err = retrieve("spec.yaml")
+	require.ErrorContains(t, err, "Local authenticator")
+	require.Empty(t, retr.repos)
+}
+
+func TestPinnerRetrieveSHA256(t *testing.T) {
+	const pinned = "ec85029a240bf6078595f69ca2f33df13e7cd238b0ec0b80aaed9f9b50988b2e"
+	modFile := filepath.Join(t.TempDir(), "modules.yaml")
+	err := os.WriteFile(modFile, []byte("version: 1\nimports:\n    github.com/foo/bar:\n        pinned: "+pinned+"\n"), 0644)
+	require.NoError(t, err)
+
+	retr := mock.Retriever{}
+	pinner, err := New(modFile, retr)
+	require.NoError(t, err)
+
+	resource := &retriever.Resource{Repo: "github.com/foo/bar", Filepath: "baz.md"}
+	result, err := pinner.RetrieveWithInfo(context.Background(), resource)
+	require.NoError(t, err)
+	require.Equal(t, retr.HashContent(), result.Content)
+	require.Equal(t, pinned, result.Hash.String())
+	resolved := &retriever.Resource{Repo: resource.Repo, Filepath: resource.Filepath, Ref: result.Reference()}
+	require.Equal(t, "github.com/foo/bar/baz.md@"+pinned, resolved.Path())
+}
+
+func TestPinnerRetrieveWithInfo(t *testing.T) {
+	tmp := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmp, "spec.yaml"), []byte("local content"), 0644))
+	retr := mock.Retriever{}
+	pinner, err := New(filepath.Join(tmp, "modules.yaml"), retr)
+	require.NoError(t, err)
+
+	// The information of the wrapped retriever is returned.
+	resource := &retriever.Resource{Repo: "github.com/foo/bar", Filepath: "baz.md", Ref: retriever.NewSymbolicReference("v1")}
+	result, err := pinner.RetrieveWithInfo(context.Background(), resource)
+	require.NoError(t, err)
+	require.Equal(t, retr.TagContent(), result.Content)
+	require.Equal(t, retr.TagHash(), result.Hash)
+	require.Equal(t, "v1", result.Ref)
+	require.Equal(t, retriever.ReferenceTypeTag, result.RefType)
+	require.Equal(t, int64(len(retr.TagContent())), result.Size)
+
+	// Pinned references are reported with their pinned hash.
+	result, err = pinner.RetrieveWithInfo(context.Background(), &retriever.Resource{Repo: "github.com/foo/bar", Filepath: "baz.md"})
+	require.NoError(t, err)
+	require.Equal(t, retr.TagHash(), result.Hash)
+	require.Equal(t, "v1", result.Ref)
+
+	// Local replacements report the file of the working tree.
+	require.NoError(t, pinner.Replace("github.com/org/specs", &Replace{Repo: "../" + filepath.Base(tmp)}))
+	result, err = pinner.RetrieveWithInfo(context.Background(), &retriever.Resource{Repo: "github.com/org/specs", Filepath: "spec.yaml"})
+	require.NoError(t, err)
+	require.Equal(t, "local content", string(result.Content))
+	require.Equal(t, int64(len("local content")), result.Size)
+	require.Equal(t, os.FileMode(0644), result.Mode)
+	require.True(t, result.Hash.IsZero())
+}
+
+// movedTagRetriever is a mock retriever whose remote repositories advertise tag v1 at a new commit.
+type movedTagRetriever struct {
+	mock.Retriever
+}
+
+func (movedTagRetriever) MovedHash() retriever.Hash {
+	h, _ := retriever.NewHash("433416d690dbffc8fe321e12bdd4f21d79e2a479")
+	return h
+}
+
+func (r movedTagRetriever) ResolveRemoteTag(_ context.Context, _, tag string) (retriever.Hash, bool, error) {
+	return r.MovedHash(), tag == "v1", nil
+}
+
+func TestPinnerTagPolicy(t *testing.T) {
+	retr := movedTagRetriever{}
+	modContent := fmt.Sprintf("version: 1\nimports:\n    github.com/foo/bar:\n        ref: v1\n        pinned: %s\n", retr.TagHash())
+	resource := &retriever.Resource{Repo: "github.com/foo/bar", Filepath: "baz.md"}
+	ctx := context.Background()
+
+	for _, policy := range []retriever.TagPolicy{retriever.TagPolicyTrust, retriever.TagPolicyWarn} {
+		modFile := filepath.Join(t.TempDir(), "modules.yaml")
+		require.NoError(t, os.WriteFile(modFile, []byte(modContent), 0644))
+		pinner, err := New(modFile, retr)
+		require.NoError(t, err)
+		result, err := pinner.WithTagPolicy(policy).RetrieveWithInfo(ctx, resource)
+		require.NoError(t, err, policy.String())
+		require.Equal(t, retr.TagHash(), result.Hash, policy.String())
+	}
+
+	modFile := filepath.Join(t.TempDir(), "modules.yaml")
+	require.NoError(t, os.WriteFile(modFile, []byte(modContent), 0644))
+	pinner, err := New(modFile, retr)
+	require.NoError(t, err)
+	_, err = pinner.WithTagPolicy(retriever.TagPolicyFail).RetrieveWithInfo(ctx, resource)
+	var moved *retriever.TagMovedError
+	require.ErrorAs(t, err, &moved)
+	require.Equal(t, retriever.TagMovedError{Repo: "github.com/foo/bar", Tag: "v1", Old: retr.TagHash(), New: retr.MovedHash()}, *moved)
+
+	// Accepted moves are pinned.
+	result, err := pinner.WithTagPolicy(retriever.TagPolicyAccept).RetrieveWithInfo(ctx, resource)
+	require.NoError(t, err)
+	require.Equal(t, retr.MovedHash(), result.Hash)
+	b, err := os.ReadFile(modFile)
+	require.NoError(t, err)
+	require.Contains(t, string(b), "pinned: "+retr.MovedHash().String())
+}
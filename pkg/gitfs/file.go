@@ -1,28 +1,41 @@
 package gitfs
 
 import (
+	"errors"
+	"io"
 	"os"
+	"path"
 	"strings"
 	"time"
 
+	"github.com/go-git/go-git/v5/plumbing/filemode"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/spf13/afero"
 )
 
+// errIsDir is returned when reading the content of a directory.
+var errIsDir = errors.New("is a directory")
+
 // gitFile is an afero.File wrapper on *object.File. It behaves just like a
 // read-only file. It does not allow any modifications on the file.
 type gitFile struct {
-	r *strings.Reader
-	f *object.File
+	r     *strings.Reader
+	f     *object.File
+	mtime time.Time
 }
 
 // NewGitFile returns a read-only afero.File based on a git file.
 func NewGitFile(f *object.File) (afero.File, error) {
+	return newGitFile(f, time.Time{})
+}
+
+// newGitFile returns a read-only afero.File based on a git file, modified at the given time (i.e. that of its commit).
+func newGitFile(f *object.File, mtime time.Time) (afero.File, error) {
 	contents, err := f.Contents()
 	if err != nil {
 		return nil, err
 	}
-	return &gitFile{f: f, r: strings.NewReader(contents)}, nil
+	return &gitFile{f: f, r: strings.NewReader(contents), mtime: mtime}, nil
 }
 
 func (g *gitFile) Close() error {
@@ -55,44 +68,54 @@ func (g *gitFile) Name() string {
 }
 
 func (g *gitFile) Readdir(count int) ([]os.FileInfo, error) {
-	panic("unimplemented")
+	return nil, &os.PathError{Op: "readdir", Path: g.f.Name, Err: errors.New("not a directory")}
 }
 
 func (g *gitFile) Readdirnames(n int) ([]string, error) {
-	panic("unimplemented")
+	return nil, &os.PathError{Op: "readdirent", Path: g.f.Name, Err: errors.New("not a directory")}
 }
 
+// GitFileInfo describes a file (or directory) within a commit.
 type GitFileInfo struct {
-	f *object.File
+	name  string
+	mode  filemode.FileMode
+	size  int64
+	mtime time.Time
+	sys   interface{} // The *object.File, *object.TreeEntry or *object.Tree.
 }
 
+// Name returns the base name of the file.
 func (g *GitFileInfo) Name() string {
-	return g.f.Name
+	return path.Base(g.name)
 }
 
 func (g *GitFileInfo) Size() int64 {
-	return g.f.Size
+	return g.size
 }
 
 func (g *GitFileInfo) Mode() os.FileMode {
-	return os.FileMode(g.f.Mode)
+	m, err := g.mode.ToOSFileMode()
+	if err != nil {
+		return os.ModeIrregular
+	}
+	return m
 }
 
+// ModTime returns the time of the commit of the file.
 func (g *GitFileInfo) ModTime() time.Time {
-	// FIXME: not really sure where I can get time, maybe from commit?
-	panic("unimplemented")
+	return g.mtime
 }
 
 func (g *GitFileInfo) IsDir() bool {
-	return !g.f.Mode.IsFile()
+	return g.mode == filemode.Dir
 }
 
 func (g *GitFileInfo) Sys() interface{} {
-	return g.f
+	return g.sys
 }
 
 func (g *gitFile) Stat() (os.FileInfo, error) {
-	return &GitFileInfo{g.f}, nil
+	return &GitFileInfo{name: g.f.Name, mode: g.f.Mode, size: g.f.Size, mtime: g.mtime, sys: g.f}, nil
 }
 
 func (g *gitFile) Sync() error {
@@ -107,3 +130,99 @@ func (g *gitFile) Truncate(size int64) error {
 func (g *gitFile) WriteString(s string) (ret int, err error) {
 	return -1, os.ErrPermission
 }
+
+// gitDir is a read-only afero.File of a directory (i.e. a tree) within a commit.
+type gitDir struct {
+	name  string
+	t     *object.Tree
+	mtime time.Time
+	off   int // The number of entries already read.
+}
+
+func newGitDir(name string, t *object.Tree, mtime time.Time) *gitDir {
+	return &gitDir{name: name, t: t, mtime: mtime}
+}
+
+func (g *gitDir) Close() error {
+	return nil
+}
+
+func (g *gitDir) Read(p []byte) (n int, err error) {
+	return 0, &os.PathError{Op: "read", Path: g.name, Err: errIsDir}
+}
+
+func (g *gitDir) ReadAt(p []byte, off int64) (n int, err error) {
+	return 0, &os.PathError{Op: "read", Path: g.name, Err: errIsDir}
+}
+
+func (g *gitDir) Seek(offset int64, whence int) (int64, error) {
+	return 0, &os.PathError{Op: "seek", Path: g.name, Err: errIsDir}
+}
+
+// Writes are not allowed
+func (g *gitDir) Write(p []byte) (n int, err error) {
+	return 0, os.ErrPermission
+}
+
+func (g *gitDir) WriteAt(p []byte, off int64) (n int, err error) {
+	return 0, os.ErrPermission
+}
+
+func (g *gitDir) Name() string {
+	return g.name
+}
+
+// Readdir returns the entries of the directory (other than submodules) in the order of the tree, i.e. sorted by name.
+// As for os.File, at most count entries are returned if count is positive (and io.EOF at the end of the directory),
+// otherwise all of the remaining entries are returned.
+func (g *gitDir) Readdir(count int) ([]os.FileInfo, error) {
+	var infos []os.FileInfo
+	for ; g.off < len(g.t.Entries) && (count <= 0 || len(infos) < count); g.off++ {
+		e := g.t.Entries[g.off]
+		info := &GitFileInfo{name: e.Name, mode: e.Mode, mtime: g.mtime}
+		switch {
+		case e.Mode == filemode.Dir:
+			t, err := g.t.Tree(e.Name)
+			if err != nil {
+				return infos, &os.PathError{Op: "readdir", Path: g.name, Err: err}
+			}
+			info.sys = t
+		case e.Mode.IsFile():
+			// The size is unknown if the blob is missing (i.e. from a partial clone).
+			info.size, _ = g.t.Size(e.Name)
+			info.sys = &e
+		default:
+			continue // Submodules aren't within the filesystem.
+		}
+		infos = append(infos, info)
+	}
+	if count > 0 && len(infos) == 0 {
+		return nil, io.EOF
+	}
+	return infos, nil
+}
+
+func (g *gitDir) Readdirnames(n int) ([]string, error) {
+	infos, err := g.Readdir(n)
+	names := make([]string, 0, len(infos))
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	return names, err
+}
+
+func (g *gitDir) Stat() (os.FileInfo, error) {
+	return &GitFileInfo{name: g.name, mode: filemode.Dir, mtime: g.mtime, sys: g.t}, nil
+}
+
+func (g *gitDir) Sync() error {
+	return nil
+}
+
+func (g *gitDir) Truncate(size int64) error {
+	return os.ErrPermission
+}
+
+func (g *gitDir) WriteString(s string) (ret int, err error) {
+	return -1, os.ErrPermission
+}
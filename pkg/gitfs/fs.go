@@ -1,10 +1,13 @@
 package gitfs
 
 import (
+	"errors"
 	"os"
+	"path"
 	"strings"
 	"time"
 
+	"github.com/go-git/go-git/v5/plumbing/filemode"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/spf13/afero"
 )
@@ -13,22 +16,55 @@ type gitMemFs struct {
 	c *object.Commit
 }
 
-// NewGitMemFs returns a read-only afero filesystem based on a commit.
+// NewGitMemFs returns a read-only afero filesystem based on a commit. Both files and directories can be opened, and
+// their modification time is the time of the commit.
 func NewGitMemFs(c *object.Commit) afero.Fs {
 	return afero.NewReadOnlyFs(&gitMemFs{c})
 }
 
 func (g *gitMemFs) Open(name string) (afero.File, error) {
+	p := cleanName(name)
+	tree, err := g.c.Tree()
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	if p == "" {
+		return newGitDir(name, tree, g.c.Committer.When), nil
+	}
+
+	entry, err := tree.FindEntry(p)
+	if errors.Is(err, object.ErrEntryNotFound) || errors.Is(err, object.ErrDirectoryNotFound) {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	} else if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	switch {
+	case entry.Mode == filemode.Dir:
+		dir, err := tree.Tree(p)
+		if err != nil {
+			return nil, &os.PathError{Op: "open", Path: name, Err: err}
+		}
+		return newGitDir(name, dir, g.c.Committer.When), nil
+	case entry.Mode.IsFile():
+		f, err := tree.File(p)
+		if err != nil {
+			return nil, &os.PathError{Op: "open", Path: name, Err: err}
+		}
+		return newGitFile(f, g.c.Committer.When)
+	default:
+		// Submodules are commits of other repositories, so aren't within the filesystem.
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+}
+
+// cleanName returns the name as a path within the tree of the commit, or empty for its root.
+func cleanName(name string) string {
 	if os.PathSeparator != '/' {
 		// go-git requires paths be seperated by `/`
 		// see this line: https://github.com/go-git/go-git/blob/v5.12.0/plumbing/object/tree.go#L135
 		name = strings.ReplaceAll(name, string(os.PathSeparator), "/")
 	}
-	f, err := g.c.File(name)
-	if err != nil {
-		return nil, err
-	}
-	return NewGitFile(f)
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
 }
 
 func (g *gitMemFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
@@ -1,12 +1,18 @@
 package gitfs
 
 import (
+	"io"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/require"
 )
 
@@ -31,3 +37,77 @@ func TestParseResource(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNil(t, file)
 }
+
+func TestGitMemFs(t *testing.T) {
+	r, err := git.Init(memory.NewStorage(), memfs.New())
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+	for name, content := range map[string]string{"README.md": "readme", "api/spec.yaml": "spec", "api/v1/types.yaml": "types"} {
+		require.NoError(t, util.WriteFile(w.Filesystem, name, []byte(content), 0644))
+		_, err = w.Add(name)
+		require.NoError(t, err)
+	}
+	when := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	sig := &object.Signature{Name: "Tester", Email: "email@address.com", When: when}
+	hash, err := w.Commit("commit", &git.CommitOptions{Author: sig, Committer: sig})
+	require.NoError(t, err)
+	commit, err := r.CommitObject(hash)
+	require.NoError(t, err)
+
+	fs := NewGitMemFs(commit)
+
+	info, err := fs.Stat("api/spec.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "spec.yaml", info.Name())
+	require.False(t, info.IsDir())
+	require.Equal(t, int64(4), info.Size())
+	require.Equal(t, os.FileMode(0644), info.Mode())
+	require.True(t, when.Equal(info.ModTime()))
+
+	b, err := afero.ReadFile(fs, "/api/v1/types.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "types", string(b))
+
+	info, err = fs.Stat("api")
+	require.NoError(t, err)
+	require.True(t, info.IsDir())
+	require.Equal(t, "api", info.Name())
+
+	infos, err := afero.ReadDir(fs, "api")
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	require.Equal(t, "spec.yaml", infos[0].Name())
+	require.Equal(t, int64(4), infos[0].Size())
+	require.Equal(t, "v1", infos[1].Name())
+	require.True(t, infos[1].IsDir())
+
+	dir, err := fs.Open("")
+	require.NoError(t, err)
+	names, err := dir.Readdirnames(1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"README.md"}, names)
+	names, err = dir.Readdirnames(1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"api"}, names)
+	_, err = dir.Readdirnames(1)
+	require.ErrorIs(t, err, io.EOF)
+	_, err = dir.Read(make([]byte, 1))
+	require.Error(t, err)
+
+	var walked []string
+	require.NoError(t, afero.Walk(fs, "", func(path string, info os.FileInfo, err error) error {
+		walked = append(walked, path)
+		return err
+	}))
+	require.Equal(t, []string{"", "README.md", "api", "api/spec.yaml", "api/v1", "api/v1/types.yaml"}, walked)
+
+	_, err = fs.Stat("missing.yaml")
+	require.ErrorIs(t, err, os.ErrNotExist)
+	exists, err := afero.Exists(fs, "api/missing.yaml")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	_, err = fs.Create("new.yaml")
+	require.Error(t, err)
+}
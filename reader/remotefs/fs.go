@@ -0,0 +1,168 @@
+package remotefs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/spf13/afero"
+	"github.com/spf13/afero/mem"
+
+	"github.com/anz-bank/golden-retriever/pkg/gitfs"
+	"github.com/anz-bank/golden-retriever/retriever"
+)
+
+// CommitRetriever is implemented by retrievers that can return the commit of a resource (e.g. git.Git), allowing
+// remote directories to be opened.
+type CommitRetriever interface {
+	RetrieveCommit(ctx context.Context, resource *retriever.Resource) (*object.Commit, error)
+}
+
+// Open opens the file (either local or remote) for reading. Remote files are retrieved with the retriever, and remote
+// directories are opened within the commit of their reference if the retriever implements CommitRetriever.
+func (r *RemoteFs) Open(name string) (afero.File, error) {
+	if !r.IsRemote(name) {
+		return r.Fs.Open(name)
+	}
+	return r.openRemote(context.Background(), name)
+}
+
+// OpenFile opens the file (either local or remote). Remote files can only be opened for reading.
+func (r *RemoteFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if !r.IsRemote(name) {
+		return r.Fs.OpenFile(name, flag, perm)
+	}
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+	}
+	return r.openRemote(context.Background(), name)
+}
+
+// Stat returns the FileInfo of the file (either local or remote).
+func (r *RemoteFs) Stat(name string) (os.FileInfo, error) {
+	if !r.IsRemote(name) {
+		return r.Fs.Stat(name)
+	}
+	f, err := r.openRemote(context.Background(), name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return f.Stat()
+}
+
+func (r *RemoteFs) openRemote(ctx context.Context, name string) (afero.File, error) {
+	resource, err := r.ParseResource(name)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	result, err := r.readRemote(ctx, resource)
+	if err == nil {
+		return newRemoteFile(name, result), nil
+	}
+
+	// The path may be a directory (or not exist), which is only known from the tree of its commit.
+	cr, ok := r.retriever.(CommitRetriever)
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	commit, cerr := cr.RetrieveCommit(ctx, resource)
+	if cerr != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	f, err := gitfs.NewGitMemFs(commit).Open(resource.Filepath)
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return nil, &os.PathError{Op: pathErr.Op, Path: name, Err: pathErr.Err}
+	}
+	return f, err
+}
+
+// newRemoteFile returns a read-only file of the content of a retrieved remote file, with its mode (without write
+// permissions) and the time of its commit (if known).
+func newRemoteFile(name string, result *retriever.Result) afero.File {
+	data := mem.CreateFile(name)
+	_, _ = mem.NewFileHandle(data).Write(result.Content)
+	mode := os.FileMode(0444)
+	if result.Mode != 0 {
+		mode = result.Mode &^ 0222
+	}
+	mem.SetMode(data, mode)
+	mem.SetModTime(data, result.Time)
+	return mem.NewReadOnlyFileHandle(data)
+}
+
+// Create fails for remote files, which are read-only.
+func (r *RemoteFs) Create(name string) (afero.File, error) {
+	if r.IsRemote(name) {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+	}
+	return r.Fs.Create(name)
+}
+
+// Mkdir fails for remote directories, which are read-only.
+func (r *RemoteFs) Mkdir(name string, perm os.FileMode) error {
+	if r.IsRemote(name) {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrPermission}
+	}
+	return r.Fs.Mkdir(name, perm)
+}
+
+// MkdirAll fails for remote directories, which are read-only.
+func (r *RemoteFs) MkdirAll(path string, perm os.FileMode) error {
+	if r.IsRemote(path) {
+		return &os.PathError{Op: "mkdir", Path: path, Err: os.ErrPermission}
+	}
+	return r.Fs.MkdirAll(path, perm)
+}
+
+// Remove fails for remote files, which are read-only.
+func (r *RemoteFs) Remove(name string) error {
+	if r.IsRemote(name) {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrPermission}
+	}
+	return r.Fs.Remove(name)
+}
+
+// RemoveAll fails for remote files, which are read-only.
+func (r *RemoteFs) RemoveAll(path string) error {
+	if r.IsRemote(path) {
+		return &os.PathError{Op: "remove", Path: path, Err: os.ErrPermission}
+	}
+	return r.Fs.RemoveAll(path)
+}
+
+// Rename fails for remote files, which are read-only.
+func (r *RemoteFs) Rename(oldname, newname string) error {
+	if r.IsRemote(oldname) || r.IsRemote(newname) {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: os.ErrPermission}
+	}
+	return r.Fs.Rename(oldname, newname)
+}
+
+// Chmod fails for remote files, which are read-only.
+func (r *RemoteFs) Chmod(name string, mode os.FileMode) error {
+	if r.IsRemote(name) {
+		return &os.PathError{Op: "chmod", Path: name, Err: os.ErrPermission}
+	}
+	return r.Fs.Chmod(name, mode)
+}
+
+// Chown fails for remote files, which are read-only.
+func (r *RemoteFs) Chown(name string, uid, gid int) error {
+	if r.IsRemote(name) {
+		return &os.PathError{Op: "chown", Path: name, Err: os.ErrPermission}
+	}
+	return r.Fs.Chown(name, uid, gid)
+}
+
+// Chtimes fails for remote files, which are read-only.
+func (r *RemoteFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if r.IsRemote(name) {
+		return &os.PathError{Op: "chtimes", Path: name, Err: os.ErrPermission}
+	}
+	return r.Fs.Chtimes(name, atime, mtime)
+}
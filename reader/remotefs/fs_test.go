@@ -0,0 +1,71 @@
+package remotefs
+
+import (
+	"os"
+	"testing"
+
+	"github.com/anz-bank/golden-retriever/reader/filesystem"
+	"github.com/anz-bank/golden-retriever/retriever/git"
+	"github.com/anz-bank/golden-retriever/retriever/mock"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteFsOpen(t *testing.T) {
+	dir := initLocalRepo(t, map[string]string{
+		"README.md":         "readme\n",
+		"api/spec.yaml":     "one\ntwo\n",
+		"api/v1/types.yaml": "types\n",
+	})
+
+	local := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(local, "local.yaml", []byte("local"), 0644))
+	g := git.NewWithCache(&git.AuthOptions{Local: true}, git.NewPlainFscache(t.TempDir()))
+	fs := New(filesystem.New(local), g)
+	remote := "git+file://" + dir + "//"
+
+	b, err := afero.ReadFile(fs, remote+"api/spec.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "one\ntwo\n", string(b))
+	b, err = afero.ReadFile(fs, remote+"api/spec.yaml#L2")
+	require.NoError(t, err)
+	require.Equal(t, "two\n", string(b))
+
+	info, err := fs.Stat(remote + "api/spec.yaml@main")
+	require.NoError(t, err)
+	require.False(t, info.IsDir())
+	require.Equal(t, int64(8), info.Size())
+	require.Equal(t, os.FileMode(0444), info.Mode())
+	require.False(t, info.ModTime().IsZero())
+
+	info, err = fs.Stat(remote + "api")
+	require.NoError(t, err)
+	require.True(t, info.IsDir())
+	infos, err := afero.ReadDir(fs, remote+"api")
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	require.Equal(t, "spec.yaml", infos[0].Name())
+	require.Equal(t, "v1", infos[1].Name())
+
+	exists, err := afero.Exists(fs, remote+"api/missing.yaml")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	_, err = fs.OpenFile(remote+"api/spec.yaml", os.O_RDWR, 0644)
+	require.ErrorIs(t, err, os.ErrPermission)
+	require.ErrorIs(t, fs.Remove(remote+"api/spec.yaml"), os.ErrPermission)
+	f, err := fs.Open(remote + "api/spec.yaml")
+	require.NoError(t, err)
+	_, err = f.Write([]byte("change"))
+	require.Error(t, err)
+
+	// Local paths are still read from the local filesystem.
+	b, err = afero.ReadFile(fs, "local.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "local", string(b))
+	require.NoError(t, fs.Remove("local.yaml"))
+
+	// Remote directories require a retriever of commits.
+	_, err = New(filesystem.New(local), mock.Retriever{}).Open("git+https://github.com/foo/bar//dir@unknown")
+	require.Error(t, err)
+}
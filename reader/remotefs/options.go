@@ -0,0 +1,99 @@
+package remotefs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/anz-bank/golden-retriever/retriever"
+	"github.com/anz-bank/golden-retriever/retriever/git"
+)
+
+// CacheDirEnv is the environment variable overriding the default directory git repositories are cached in.
+const CacheDirEnv = "GOLDEN_RETRIEVER_CACHE"
+
+// cacheDirName is the name of the default cache directory within the user (or temporary) cache directory.
+const cacheDirName = "anz-bank.golden-retriever"
+
+// Options configures the git retriever of NewWithGitRetrieverOptions and NewPinnerGitRetrieverOptions.
+type Options struct {
+	AuthOptions   *git.AuthOptions
+	CacheDir      string // The directory git repositories are cached in, or DefaultCacheDir() if empty.
+	NoForcedFetch bool   // True to not fetch references whose commits (and files) are already cached.
+	Offline       bool   // True to never contact remote repositories (see ErrOffline).
+
+	// Freshness describes how long fetched branches (and HEAD) are read for without being fetched again.
+	Freshness git.FreshnessPolicy
+
+	// TagPolicy describes how to handle tags (and, for NewPinnerGitRetrieverOptions, pinned tags) that have moved.
+	TagPolicy retriever.TagPolicy
+}
+
+// DefaultCacheDir returns the directory git repositories are cached in by default: that of the GOLDEN_RETRIEVER_CACHE
+// environment variable if set, otherwise anz-bank.golden-retriever within the user cache directory, falling back to
+// a directory private to the user within the temporary directory if the user has no cache directory (e.g. $HOME isn't
+// set).
+func DefaultCacheDir() string {
+	if dir := os.Getenv(CacheDirEnv); dir != "" {
+		return dir
+	}
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		log.Debugf("no user cache directory, caching git repositories in the temporary directory: %s", err)
+		return tempCacheDir()
+	}
+	return filepath.Join(userCacheDir, cacheDirName)
+}
+
+// tempCacheDir returns the cache directory of the user within the temporary directory, creating it accessible only
+// by the user. The temporary directory may be shared with other users (e.g. /tmp), so the directory is named after the
+// uid of the user and isn't used if others could have planted repositories in it: a new private directory is created
+// instead.
+func tempCacheDir() string {
+	uid := os.Getuid()
+	if uid == -1 {
+		// e.g. on windows, where the temporary directory is that of the user.
+		return filepath.Join(os.TempDir(), cacheDirName)
+	}
+	dir := filepath.Join(os.TempDir(), fmt.Sprintf("%s-%d", cacheDirName, uid))
+	if err := os.Mkdir(dir, 0700); err != nil && !os.IsExist(err) {
+		log.Debugf("error creating cache directory: %s", err)
+	} else if info, err := os.Lstat(dir); err == nil && info.IsDir() && info.Mode().Perm()&0077 == 0 {
+		return dir
+	}
+
+	log.Warnf("cache directory: %s isn't private to the user, caching git repositories in a new directory", dir)
+	private, err := os.MkdirTemp("", cacheDirName+"-")
+	if err != nil {
+		log.Errorf("error creating cache directory: %s", err)
+		return fmt.Sprintf("%s-%d", dir, os.Getpid())
+	}
+	return private
+}
+
+// globalOptions returns the options of the deprecated package variables with the given authentication options.
+func globalOptions(options *git.AuthOptions) *Options {
+	return &Options{AuthOptions: options, CacheDir: CacheDir, NoForcedFetch: NoForcedFetch, Offline: Offline}
+}
+
+// newGit returns the git retriever configured by the options (or the defaults, if nil).
+func (o *Options) newGit() *git.Git {
+	if o == nil {
+		o = &Options{}
+	}
+	dir := o.CacheDir
+	if dir == "" {
+		dir = DefaultCacheDir()
+	}
+	log.Debugf("cached git repositories folder: %s", dir)
+	return git.NewWithOptions(&git.NewGitOptions{
+		AuthOptions:   o.AuthOptions,
+		Cacher:        git.NewPlainFscache(dir),
+		NoForcedFetch: o.NoForcedFetch,
+		Offline:       o.Offline,
+		Freshness:     o.Freshness,
+		TagPolicy:     o.TagPolicy,
+	})
+}
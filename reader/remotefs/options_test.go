@@ -0,0 +1,103 @@
+package remotefs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/anz-bank/golden-retriever/reader/filesystem"
+	"github.com/anz-bank/golden-retriever/retriever"
+	"github.com/anz-bank/golden-retriever/retriever/git"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultCacheDir(t *testing.T) {
+	t.Setenv(CacheDirEnv, "/tmp/cache")
+	require.Equal(t, "/tmp/cache", DefaultCacheDir())
+
+	if runtime.GOOS != "linux" {
+		t.Skip("the user cache directory is only configured by environment variables on linux")
+	}
+	t.Setenv(CacheDirEnv, "")
+	t.Setenv("XDG_CACHE_HOME", "/tmp/xdg")
+	require.Equal(t, filepath.Join("/tmp/xdg", cacheDirName), DefaultCacheDir())
+
+	// Without a user cache directory, a directory private to the user within the temporary directory is used.
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	t.Setenv("XDG_CACHE_HOME", "")
+	t.Setenv("HOME", "")
+	dir := filepath.Join(tmp, fmt.Sprintf("%s-%d", cacheDirName, os.Getuid()))
+	require.Equal(t, dir, DefaultCacheDir())
+	info, err := os.Stat(dir)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0700), info.Mode().Perm())
+	require.Equal(t, dir, DefaultCacheDir())
+
+	// Directories others could have planted repositories in aren't used.
+	require.NoError(t, os.Chmod(dir, 0777))
+	private := DefaultCacheDir()
+	require.NotEqual(t, dir, private)
+	require.Equal(t, tmp, filepath.Dir(private))
+	info, err = os.Stat(private)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0700), info.Mode().Perm())
+}
+
+func TestNewWithGitRetrieverOptions(t *testing.T) {
+	dir := initLocalRepo(t, map[string]string{"README.md": "readme\n"})
+
+	// Each RemoteFs caches repositories in its own directory.
+	ctx := context.Background()
+	for _, cache := range []string{t.TempDir(), t.TempDir()} {
+		fs, err := NewWithGitRetrieverOptions(filesystem.New(afero.NewMemMapFs()), &Options{
+			AuthOptions: &git.AuthOptions{Local: true},
+			CacheDir:    cache,
+		})
+		require.NoError(t, err)
+		b, err := fs.Read(ctx, "git+file://"+dir+"//README.md@main")
+		require.NoError(t, err)
+		require.Equal(t, "readme\n", string(b))
+		require.DirExists(t, git.NewPlainFscache(cache).RepoDir(dir))
+	}
+
+	// The environment variable is honoured by default.
+	cache := t.TempDir()
+	t.Setenv(CacheDirEnv, cache)
+	r, err := NewPinnerGitRetrieverOptions(filepath.Join(t.TempDir(), "modules.yaml"), &Options{
+		AuthOptions: &git.AuthOptions{Local: true},
+		Offline:     true,
+	})
+	require.NoError(t, err)
+	fs := NewWithRetriever(filesystem.New(afero.NewMemMapFs()), r)
+	_, err = fs.Read(ctx, "git+file://"+dir+"//README.md@main")
+	require.ErrorIs(t, err, ErrOffline)
+	require.NoDirExists(t, git.NewPlainFscache(cache).RepoDir(dir))
+}
+
+func TestNewPinnerGitRetrieverOptions_OfflineTagPolicy(t *testing.T) {
+	dir := initLocalRepo(t, map[string]string{"README.md": "readme\n"})
+	runGit(t, dir, "tag", "v1.0.0")
+
+	// Pinned tags are read from the cache without being checked while offline.
+	ctx := context.Background()
+	modFile := filepath.Join(t.TempDir(), "modules.yaml")
+	cache := t.TempDir()
+	for _, offline := range []bool{false, true} {
+		r, err := NewPinnerGitRetrieverOptions(modFile, &Options{
+			AuthOptions: &git.AuthOptions{Local: true},
+			CacheDir:    cache,
+			Offline:     offline,
+			TagPolicy:   retriever.TagPolicyWarn,
+		})
+		require.NoError(t, err)
+		fs := NewWithRetriever(filesystem.New(afero.NewMemMapFs()), r)
+		b, err := fs.Read(ctx, "git+file://"+dir+"//README.md@v1.0.0")
+		require.NoError(t, err, "offline: %v", offline)
+		require.Equal(t, "readme\n", string(b))
+	}
+}
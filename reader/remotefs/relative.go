@@ -0,0 +1,78 @@
+package remotefs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/anz-bank/golden-retriever/retriever"
+)
+
+// ResolveRelative returns the resource of the path relative to the remote resource (or to the root of its repository if
+// the path begins with /), e.g. ../common/types.yaml imported by github.com/org/specs/a/b.yaml@v1.
+//
+// The returned resource is within the same repository at the same commit as the remote resource: its reference is
+// resolved to the commit it was last read at (retrieving the resource if it hasn't been read), so that all of the
+// files imported (transitively) by a remote file are read from the commit of the file, even if its reference moves.
+func (r *RemoteFs) ResolveRelative(ctx context.Context, resource *retriever.Resource, rel string) (*retriever.Resource, error) {
+	resolved, err := resource.Relative(rel)
+	if err != nil {
+		return nil, err
+	}
+	if resolved.Ref, err = r.commit(ctx, resource); err != nil {
+		return nil, fmt.Errorf("error resolving commit of resource: %v: %w", resource, err)
+	}
+	return resolved, nil
+}
+
+// ResolveRelativePath is as ResolveRelative for a remote path (as read by Read), returning the canonical URI of the
+// resource of the relative path, which can be read in turn.
+func (r *RemoteFs) ResolveRelativePath(ctx context.Context, path, rel string) (string, error) {
+	if !r.IsRemote(path) {
+		return "", fmt.Errorf("path is not a remote file: %s", path)
+	}
+	resource, err := r.ParseResource(path)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := r.ResolveRelative(ctx, resource, rel)
+	if err != nil {
+		return "", err
+	}
+	return resolved.String(), nil
+}
+
+// commit returns the reference of the resource resolved to its commit, retrieving the resource if it hasn't been read.
+func (r *RemoteFs) commit(ctx context.Context, resource *retriever.Resource) (*retriever.Reference, error) {
+	if resource.Ref != nil && resource.Ref.IsHash() {
+		return resource.Ref.Clone(), nil
+	}
+	if ref, ok := r.commits.Load(commitKey(resource)); ok {
+		return ref.(*retriever.Reference).Clone(), nil
+	}
+	result, err := retriever.RetrieveWithInfo(ctx, r.retriever, resource)
+	if err != nil {
+		return nil, err
+	}
+	if result.Hash.IsZero() {
+		return nil, fmt.Errorf("retriever didn't resolve the commit of resource: %v", resource)
+	}
+	ref := result.Reference()
+	r.setCommit(resource, ref)
+	return ref.Clone(), nil
+}
+
+// setCommit records the commit that the reference of the resource was (last) resolved to, e.g. replacing the commit of
+// a branch that has been fetched again.
+func (r *RemoteFs) setCommit(resource *retriever.Resource, ref *retriever.Reference) {
+	if !ref.Hash().IsZero() {
+		r.commits.Store(commitKey(resource), ref.Clone())
+	}
+}
+
+func commitKey(resource *retriever.Resource) string {
+	ref := retriever.HEAD
+	if resource.Ref != nil {
+		ref = resource.Ref.String()
+	}
+	return resource.Repo + "@" + ref
+}
@@ -0,0 +1,107 @@
+package remotefs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/anz-bank/golden-retriever/reader/filesystem"
+	"github.com/anz-bank/golden-retriever/retriever"
+	"github.com/anz-bank/golden-retriever/retriever/mock"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRetriever counts the resources retrieved by the mock retriever.
+type countingRetriever struct {
+	mock.Retriever
+	count int
+}
+
+func (c *countingRetriever) Retrieve(ctx context.Context, resource *retriever.Resource) ([]byte, error) {
+	c.count++
+	return c.Retriever.Retrieve(ctx, resource)
+}
+
+func (c *countingRetriever) RetrieveWithInfo(ctx context.Context, resource *retriever.Resource) (*retriever.Result, error) {
+	c.count++
+	return c.Retriever.RetrieveWithInfo(ctx, resource)
+}
+
+func TestResolveRelative(t *testing.T) {
+	retr := &countingRetriever{}
+	r := New(filesystem.New(afero.NewMemMapFs()), retr)
+	ctx := context.Background()
+
+	// The commit of a resource that hasn't been read is resolved by retrieving it.
+	resource := &retriever.Resource{Repo: "github.com/org/specs", Filepath: "a/b.yaml", Ref: retriever.NewSymbolicReference("v1")}
+	resolved, err := r.ResolveRelative(ctx, resource, "../common/types.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "common/types.yaml", resolved.Filepath)
+	require.Equal(t, "github.com/org/specs", resolved.Repo)
+	require.Equal(t, retr.TagHash(), resolved.Ref.Hash())
+	require.Equal(t, 1, retr.count)
+	require.Equal(t, retriever.NewSymbolicReference("v1"), resource.Ref)
+
+	// The commit of a resource that has been read is reused.
+	_, _, _, err = r.ReadHashBranch(ctx, "github.com/org/other/a/b.yaml")
+	require.NoError(t, err)
+	require.Equal(t, 2, retr.count)
+	uri, err := r.ResolveRelativePath(ctx, "github.com/org/other/a/b.yaml", "c.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "git+https://github.com/org/other//a/c.yaml@"+retr.HEADHash().String(), uri)
+	require.Equal(t, 2, retr.count)
+
+	// Imports of imports are read from the same commit.
+	uri, err = r.ResolveRelativePath(ctx, uri, "/d.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "git+https://github.com/org/other//d.yaml@"+retr.HEADHash().String(), uri)
+	require.Equal(t, 2, retr.count)
+	b, h, _, err := r.ReadHashBranch(ctx, uri)
+	require.NoError(t, err)
+	require.Equal(t, retr.HashContent(), b)
+	require.Equal(t, retr.HEADHash(), h)
+
+	_, err = r.ResolveRelativePath(ctx, "github.com/org/other/a/b.yaml", "../../c.yaml")
+	require.Error(t, err)
+	_, err = r.ResolveRelativePath(ctx, "a/b.yaml", "c.yaml")
+	require.Error(t, err)
+}
+
+// movingRetriever retrieves every resource from the current commit of its branch.
+type movingRetriever struct {
+	hash retriever.Hash
+}
+
+func (m *movingRetriever) Retrieve(ctx context.Context, resource *retriever.Resource) ([]byte, error) {
+	result, err := m.RetrieveWithInfo(ctx, resource)
+	if err != nil {
+		return nil, err
+	}
+	return result.Content, nil
+}
+
+func (m *movingRetriever) RetrieveWithInfo(_ context.Context, resource *retriever.Resource) (*retriever.Result, error) {
+	return &retriever.Result{Content: []byte(m.hash.String()), Hash: m.hash, Ref: "main", RefType: retriever.ReferenceTypeBranch, Remote: resource.Repo}, nil
+}
+
+// Verify that imports are resolved to the commit their file was last read at.
+func TestResolveRelative_Moved(t *testing.T) {
+	old, err := retriever.NewHash("1e7c4cecaaa8f76e3c668cebc411f1b03171501f")
+	require.NoError(t, err)
+	moved, err := retriever.NewHash("433416d690dbffc8fe321e12bdd4f21d79e2a479")
+	require.NoError(t, err)
+	retr := &movingRetriever{hash: old}
+	r := New(filesystem.New(afero.NewMemMapFs()), retr)
+	ctx := context.Background()
+
+	const path = "github.com/org/specs/a/b.yaml@main"
+	for _, h := range []retriever.Hash{old, moved} {
+		retr.hash = h
+		_, hash, err := r.ReadHash(ctx, path)
+		require.NoError(t, err)
+		require.Equal(t, h, hash)
+		uri, err := r.ResolveRelativePath(ctx, path, "c.yaml")
+		require.NoError(t, err)
+		require.Equal(t, "git+https://github.com/org/specs//a/c.yaml@"+h.String(), uri)
+	}
+}
@@ -3,11 +3,10 @@ package remotefs
 import (
 	"context"
 	"fmt"
-	"io/ioutil"
-	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/anz-bank/golden-retriever/pinner"
 	"github.com/anz-bank/golden-retriever/reader"
@@ -27,6 +26,9 @@ type RemoteFs struct {
 	*filesystem.Fs
 	retriever retriever.Retriever
 	vendorDir string
+	vendor    *VendorManifest // The manifest of the vendor directory, once read.
+	vendorMu  sync.Mutex
+	commits   sync.Map // The references resolved to commits (by repository and reference) of the remote files read.
 }
 
 // New initializes and returns an instance of RemoteFs.
@@ -37,27 +39,53 @@ func New(fs *filesystem.Fs, retriever retriever.Retriever) *RemoteFs {
 	}
 }
 
-var CacheDir string
+// CacheDir is the directory git repositories are cached in by NewWithGitRetriever and NewPinnerGitRetriever.
+//
+// Deprecated: CacheDir is shared by every RemoteFs, use Options.CacheDir instead.
+var CacheDir = DefaultCacheDir()
+
+// NoForcedFetch is passed to the git retrievers of NewWithGitRetriever and NewPinnerGitRetriever.
+//
+// Deprecated: NoForcedFetch is shared by every RemoteFs, use Options.NoForcedFetch instead.
 var NoForcedFetch bool
 
-func init() {
-	userCacheDir, err := os.UserCacheDir()
-	if err != nil {
-		panic(err)
-	}
-	CacheDir = filepath.Join(userCacheDir, "anz-bank.golden-retriever")
-}
+// Offline forbids the git retrievers returned by NewWithGitRetriever and NewPinnerGitRetriever from contacting remote
+// repositories, so that remote files are read from the cache (or vendor directory, or pinned to hashes) only, failing
+// with ErrOffline otherwise.
+//
+// Deprecated: Offline is shared by every RemoteFs, use Options.Offline instead.
+var Offline bool
+
+// ErrOffline is returned (wrapped) when reading a remote file that isn't available locally in offline mode.
+var ErrOffline = git.ErrOffline
 
 // NewWithGitRetriever initializes and returns an instance of RemoteFs with retriever git.Git.
 func NewWithGitRetriever(fs *filesystem.Fs, options *git.AuthOptions) (*RemoteFs, error) {
-	log.Debugf("cached git repositories folder: %s", CacheDir)
-	return New(fs, git.NewWithOptions(&git.NewGitOptions{options, git.NewPlainFscache(CacheDir), NoForcedFetch})), nil
+	return NewWithGitRetrieverOptions(fs, globalOptions(options))
 }
 
 // NewPinnerGitRetriever initializes and returns an instance of pinner.Pinner.
 func NewPinnerGitRetriever(modFile string, options *git.AuthOptions) (retriever.Retriever, error) {
-	log.Debugf("cached git repositories folder: %s", CacheDir)
-	return pinner.New(modFile, git.NewWithOptions(&git.NewGitOptions{options, git.NewPlainFscache(CacheDir), NoForcedFetch}))
+	return NewPinnerGitRetrieverOptions(modFile, globalOptions(options))
+}
+
+// NewWithGitRetrieverOptions initializes and returns an instance of RemoteFs with retriever git.Git configured by the
+// options (or the defaults, if nil).
+func NewWithGitRetrieverOptions(fs *filesystem.Fs, options *Options) (*RemoteFs, error) {
+	return New(fs, options.newGit()), nil
+}
+
+// NewPinnerGitRetrieverOptions initializes and returns an instance of pinner.Pinner wrapping retriever git.Git
+// configured by the options (or the defaults, if nil).
+func NewPinnerGitRetrieverOptions(modFile string, options *Options) (retriever.Retriever, error) {
+	p, err := pinner.New(modFile, options.newGit())
+	if err != nil {
+		return nil, err
+	}
+	if options != nil {
+		p.WithTagPolicy(options.TagPolicy)
+	}
+	return p, nil
 }
 
 // NewWithRetriever initializes and returns an instance of RemoteFs with a retriever.
@@ -83,39 +111,41 @@ func (r *RemoteFs) ReadHashBranch(ctx context.Context, path string) ([]byte, ret
 		if err != nil {
 			return nil, retriever.ZeroHash, "", err
 		}
-
-		if r.vendorDir != "" {
-			if _, err := os.Stat(filepath.Join(r.vendorDir, path)); err == nil {
-				body, err := ioutil.ReadFile(filepath.Join(r.vendorDir, path))
-				if err == nil {
-					return body, resource.Ref.Hash(), resource.Ref.Name(), nil
-				}
-			}
-		}
-
-		b, err := r.retriever.Retrieve(ctx, resource)
+		result, err := r.readRemote(ctx, resource)
 		if err != nil {
-			return nil, retriever.ZeroHash, "", err
+			return nil, result.Hash, result.Ref, err
 		}
+		return result.Content, result.Hash, result.Ref, nil
+	}
 
-		if r.vendorDir != "" {
-			p := filepath.Join(r.vendorDir, resource.String())
-			err = os.MkdirAll(filepath.Dir(p), os.ModePerm)
-			if err != nil {
-				return nil, resource.Ref.Hash(), resource.Ref.Name(), err
-			}
-			err = ioutil.WriteFile(p, b, 0644)
-			if err != nil {
-				return nil, resource.Ref.Hash(), resource.Ref.Name(), err
-			}
-		}
+	return r.Fs.ReadHashBranch(ctx, path)
+}
 
-		return b, resource.Ref.Hash(), resource.Ref.Name(), nil
+// readRemote returns the content (limited to the lines of the resource, if any) of the remote file with information
+// about where it came from, reading it from the vendor directory if vendored. The result is non-nil even on failure.
+func (r *RemoteFs) readRemote(ctx context.Context, resource *retriever.Resource) (*retriever.Result, error) {
+	var result *retriever.Result
+	var err error
+	if r.vendorDir != "" {
+		result, err = r.readVendored(ctx, resource)
+	} else {
+		result, err = retriever.RetrieveWithInfo(ctx, r.retriever, resource)
 	}
+	if err != nil {
+		if result == nil {
+			result = &retriever.Result{}
+		}
+		return result, err
+	}
+	r.setCommit(resource, result.Reference())
 
-	return r.Fs.ReadHashBranch(ctx, path)
+	result.Content = resource.Lines.Extract(result.Content)
+	result.Size = int64(len(result.Content))
+	return result, nil
 }
 
+// Vendor stores the remote files read under the directory (recording them in its vendor manifest), and reads them from
+// there on subsequent reads.
 func (r *RemoteFs) Vendor(dir string) {
 	r.vendorDir = filepath.Clean(dir)
 	log.Info("vendor files are stored under", r.vendorDir)
@@ -127,8 +157,10 @@ const remoteImportPrefix = "//"
 // e.g. valid remote file paths:
 // - github.com/foo/bar/path/to/file@v0.0.1
 // - //github.com/foo/bar/path/to/file@v0.0.1
+// - git+https://gitlab.com/foo/bar/baz//path/to/file@v0.0.1#L10-L20
+// - https://github.com/foo/bar/blob/v0.0.1/path/to/file
 func (*RemoteFs) IsRemote(path string) bool {
-	if strings.HasPrefix(path, remoteImportPrefix) {
+	if strings.HasPrefix(path, remoteImportPrefix) || retriever.IsResourceURI(path) || retriever.IsWebURL(path) {
 		return true
 	}
 
@@ -142,7 +174,15 @@ func (*RemoteFs) IsRemote(path string) bool {
 // resourceRegexp is the regular expression of remote file path string. e.g. github.com/foo/bar/path/to/file@v0.0.1
 var resourceRegexp = `^((\w+\.)+(\w)+(/[\w-]+){2})((/[\w.-]+)+)(@([\w./-]+))?$`
 
-// ParseResource takes a string in certain format and returns the corresponding resource.
+// ParseResource takes a string in certain format and returns the corresponding resource. Canonical resource URIs
+// (see retriever.ParseResourceURI) are supported too, e.g. for repositories nested within groups or on other ports, as
+// are the web URLs of files on GitHub, GitLab, Bitbucket and Gitea (see retriever.ParseWebURL).
 func (*RemoteFs) ParseResource(str string) (*retriever.Resource, error) {
+	if retriever.IsResourceURI(str) {
+		return retriever.ParseResourceURI(str)
+	}
+	if strings.HasPrefix(str, "https://") || strings.HasPrefix(str, "http://") {
+		return retriever.ParseWebURL(str)
+	}
 	return retriever.ParseResource(strings.TrimPrefix(str, remoteImportPrefix), resourceRegexp, 1, 5, 8)
 }
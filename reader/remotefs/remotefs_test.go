@@ -1,9 +1,16 @@
 package remotefs
 
 import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
 
+	"github.com/anz-bank/golden-retriever/reader/filesystem"
 	"github.com/anz-bank/golden-retriever/retriever"
+	"github.com/anz-bank/golden-retriever/retriever/mock"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/require"
 )
 
@@ -25,6 +32,11 @@ func TestParseResource(t *testing.T) {
 		{"//github.com/foo/bar/file/path@ref", &retriever.Resource{Repo: "github.com/foo/bar", Filepath: "file/path", Ref: ref}, require.NoError},
 		{"//github.com/foo-foo/bar_bar/file/path.et@ref.ref", &retriever.Resource{Repo: "github.com/foo-foo/bar_bar", Filepath: "file/path.et", Ref: refref}, require.NoError},
 		{"//github.com/foo-foo/bar_bar/file/path.et@feature/ref-ref.ref", &retriever.Resource{Repo: "github.com/foo-foo/bar_bar", Filepath: "file/path.et", Ref: featurerefref}, require.NoError},
+		{"git+https://gitlab.com/foo/bar/baz//file/path@ref#L10-L20", &retriever.Resource{Repo: "gitlab.com/foo/bar/baz", Filepath: "file/path", Ref: ref, Lines: retriever.LineRange{Start: 10, End: 20}}, require.NoError},
+		{"git+https://gitlab.com/foo/bar/baz/file/path@ref", nil, require.Error},
+		{"https://github.com/foo/bar/blob/ref/file/path", &retriever.Resource{Repo: "github.com/foo/bar", Filepath: "file/path", Ref: ref}, require.NoError},
+		{"https://gitlab.com/foo/bar/baz/-/raw/ref/file/path", &retriever.Resource{Repo: "gitlab.com/foo/bar/baz", Filepath: "file/path", Ref: ref}, require.NoError},
+		{"https://github.com/foo/bar", nil, require.Error},
 	}
 
 	for _, test := range tests {
@@ -35,3 +47,45 @@ func TestParseResource(t *testing.T) {
 		})
 	}
 }
+
+func TestReadHashBranchURI(t *testing.T) {
+	retr := mock.Retriever{}
+	r := New(filesystem.New(afero.NewMemMapFs()), retr)
+	vendor := t.TempDir()
+	r.Vendor(vendor)
+
+	require.True(t, r.IsRemote("git+https://gitlab.com/foo/bar/baz//file.md@v1"))
+	b, h, branch, err := r.ReadHashBranch(context.Background(), "git+https://gitlab.com/foo/bar/baz//file.md@v1")
+	require.NoError(t, err)
+	require.Equal(t, retr.TagContent(), b)
+	require.Equal(t, retr.TagHash(), h)
+	require.Equal(t, "v1", branch)
+
+	vendored, err := os.ReadFile(filepath.Join(vendor, "gitlab.com/foo/bar/baz/file.md@"+retr.TagHash().String()))
+	require.NoError(t, err)
+	require.Equal(t, retr.TagContent(), vendored)
+
+	b, _, _, err = r.ReadHashBranch(context.Background(), "git+https://gitlab.com/foo/bar/baz//file.md@v1#L2")
+	require.NoError(t, err)
+	require.Empty(t, b)
+}
+
+// initLocalRepo initialises a repository on the main branch of a temporary directory, committing the files (keyed by
+// their path, separated by /) in one commit. It returns the directory of the repository.
+func initLocalRepo(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q", "-b", "main")
+	for file, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(file))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "-c", "user.name=Tester", "-c", "user.email=email@address.com", "commit", "-q", "-m", "commit")
+	return dir
+}
+
+// runGit runs the git command within the repository in the directory.
+func runGit(t *testing.T, dir string, args ...string) {
+	require.NoError(t, exec.Command("git", append([]string{"-C", dir}, args...)...).Run())
+}
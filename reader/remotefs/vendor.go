@@ -0,0 +1,400 @@
+package remotefs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+
+	"github.com/anz-bank/golden-retriever/retriever"
+)
+
+// VendorManifestFile is the name of the manifest of a vendor directory.
+const VendorManifestFile = "vendor.yaml"
+
+// VendorManifestVersion is the current version of the vendor manifest schema.
+const VendorManifestVersion = 1
+
+// VendorManifest records the remote files stored in a vendor directory.
+type VendorManifest struct {
+	Version int                      `yaml:"version"`
+	Files   map[string]*VendoredFile `yaml:"files"` // The vendored files by their resource (see VendorKey).
+}
+
+// VendoredFile is a remote file stored in a vendor directory.
+type VendoredFile struct {
+	Ref    string `yaml:"ref,omitempty"` // The name of the reference resolved to the commit, if any (e.g. main for HEAD).
+	Commit string `yaml:"commit"`        // The hash of the commit the file was retrieved from.
+	Path   string `yaml:"path"`          // The path of the file within the vendor directory (separated by /).
+	Sum    string `yaml:"sum"`           // The hash of the content of the file, e.g. sha256:<hex>.
+}
+
+// VendorError describes the problems found when verifying a vendor directory.
+type VendorError struct {
+	Dir      string
+	Problems []string
+}
+
+func (e *VendorError) Error() string {
+	return fmt.Sprintf("vendor directory %s is not in sync with its manifest:\n  %s", e.Dir, strings.Join(e.Problems, "\n  "))
+}
+
+// ReadVendorManifest reads the manifest of the vendor directory, which is empty if the directory has no manifest.
+func ReadVendorManifest(dir string) (*VendorManifest, error) {
+	m := &VendorManifest{Version: VendorManifestVersion, Files: make(map[string]*VendoredFile)}
+	b, err := os.ReadFile(filepath.Join(dir, VendorManifestFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Join(dir, VendorManifestFile), err)
+	}
+	if m.Version > VendorManifestVersion {
+		return nil, fmt.Errorf("%s: unsupported vendor manifest version %d, the latest supported version is %d",
+			filepath.Join(dir, VendorManifestFile), m.Version, VendorManifestVersion)
+	}
+	if m.Files == nil {
+		m.Files = make(map[string]*VendoredFile)
+	}
+	return m, nil
+}
+
+// save writes the manifest to the vendor directory.
+func (m *VendorManifest) save(dir string) error {
+	b, err := yaml.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, VendorManifestFile), b, 0644)
+}
+
+// VendorKey returns the key of the remote file of the resource within a vendor manifest, i.e. the canonical URI of
+// the entire file at the reference requested (rather than resolved).
+func VendorKey(resource *retriever.Resource) string {
+	r := resource.Clone()
+	r.Lines = retriever.LineRange{}
+	return r.String()
+}
+
+// vendorPath returns the path (separated by /) of the file of the resource within the vendor directory.
+func vendorPath(resource *retriever.Resource) string {
+	return strings.TrimPrefix(filepath.ToSlash(resource.Path()), "/")
+}
+
+// contentSum returns the hash of the content of a vendored file.
+func contentSum(content []byte) string {
+	h := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(h[:])
+}
+
+// manifest returns the manifest of the vendor directory, reading it if it hasn't been read. The caller must hold
+// vendorMu.
+func (r *RemoteFs) manifest() (*VendorManifest, error) {
+	if r.vendorDir == "" {
+		return nil, errors.New("vendoring is not enabled")
+	}
+	if r.vendor == nil {
+		m, err := ReadVendorManifest(r.vendorDir)
+		if err != nil {
+			return nil, err
+		}
+		r.vendor = m
+	}
+	return r.vendor, nil
+}
+
+// readVendored returns the content of the remote file from the vendor directory if it's in the manifest (with the
+// content recorded), otherwise it retrieves the file and vendors it.
+func (r *RemoteFs) readVendored(ctx context.Context, resource *retriever.Resource) (*retriever.Result, error) {
+	r.vendorMu.Lock()
+	defer r.vendorMu.Unlock()
+	m, err := r.manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	key := VendorKey(resource)
+	if f, ok := m.Files[key]; ok {
+		result, err := r.readVendoredFile(resource, f)
+		if err == nil {
+			return result, nil
+		}
+		log.Warnf("re-vendoring %s: %v", key, err)
+	} else if resource.Ref != nil && resource.Ref.IsHash() {
+		// Files vendored before the manifest was introduced are stored by the hash of their commit.
+		p := vendorPath(resource)
+		if b, err := os.ReadFile(filepath.Join(r.vendorDir, filepath.FromSlash(p))); err == nil {
+			m.Files[key] = &VendoredFile{Ref: resource.Ref.Name(), Commit: resource.Ref.Hash().String(), Path: p, Sum: contentSum(b)}
+			if err := m.save(r.vendorDir); err != nil {
+				return nil, err
+			}
+			return r.readVendoredFile(resource, m.Files[key])
+		}
+	}
+
+	result, err := r.vendorFile(ctx, m, key, resource)
+	if err != nil {
+		return result, err
+	}
+	return result, m.save(r.vendorDir)
+}
+
+// readVendoredFile returns the content of the vendored file, checking it's that recorded by the manifest.
+func (r *RemoteFs) readVendoredFile(resource *retriever.Resource, f *VendoredFile) (*retriever.Result, error) {
+	b, err := os.ReadFile(filepath.Join(r.vendorDir, filepath.FromSlash(f.Path)))
+	if err != nil {
+		return nil, err
+	}
+	if sum := contentSum(b); sum != f.Sum {
+		return nil, fmt.Errorf("content of vendored file %s has hash %s, expected %s", f.Path, sum, f.Sum)
+	}
+	hash := retriever.ZeroHash // Unknown, e.g. if read from the working tree of a local replacement.
+	if f.Commit != "" {
+		if hash, err = retriever.NewHash(f.Commit); err != nil {
+			return nil, fmt.Errorf("vendored file %s: %w", f.Path, err)
+		}
+	}
+	return &retriever.Result{Content: b, Hash: hash, Ref: f.Ref, Size: int64(len(b)), Remote: resource.Repo}, nil
+}
+
+// vendorFile retrieves the remote file of the resource, storing it in the vendor directory and recording it in the
+// manifest (which isn't saved). The file is stored by the hash of its commit, so files of moved references don't
+// overwrite those of other commits.
+func (r *RemoteFs) vendorFile(ctx context.Context, m *VendorManifest, key string, resource *retriever.Resource) (*retriever.Result, error) {
+	whole := resource.Clone()
+	whole.Lines = retriever.LineRange{}
+	result, err := retriever.RetrieveWithInfo(ctx, r.retriever, whole)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := &retriever.Resource{Repo: resource.Repo, Filepath: resource.Filepath, Ref: result.Reference()}
+	p := vendorPath(resolved)
+	dst := filepath.Join(r.vendorDir, filepath.FromSlash(p))
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return &retriever.Result{Hash: result.Hash, Ref: result.Ref}, err
+	}
+	if err := os.WriteFile(dst, result.Content, 0644); err != nil {
+		return &retriever.Result{Hash: result.Hash, Ref: result.Ref}, err
+	}
+	m.Files[key] = &VendoredFile{Ref: result.Ref, Commit: result.Hash.String(), Path: p, Sum: contentSum(result.Content)}
+	return result, nil
+}
+
+// VendorSync re-vendors the remote files of the paths (or every file in the vendor manifest if there are none),
+// retrieving them again so that files of branches are refreshed (and those of pinned repositories follow their pins,
+// if retrieved by a pinner). Files of the paths are added to (or updated in) the manifest, removing the files they
+// superseded. Without paths, the manifest then records exactly the files re-vendored, and files no longer referenced
+// by it are pruned.
+func (r *RemoteFs) VendorSync(ctx context.Context, paths ...string) error {
+	r.vendorMu.Lock()
+	defer r.vendorMu.Unlock()
+	m, err := r.manifest()
+	if err != nil {
+		return err
+	}
+
+	resources := make(map[string]*retriever.Resource)
+	for _, p := range paths {
+		if !r.IsRemote(p) {
+			return fmt.Errorf("path is not a remote file: %s", p)
+		}
+		resource, err := r.ParseResource(p)
+		if err != nil {
+			return err
+		}
+		resources[VendorKey(resource)] = resource
+	}
+	if len(paths) == 0 {
+		for key := range m.Files {
+			resource, err := retriever.ParseResourceURI(key)
+			if err != nil {
+				return fmt.Errorf("invalid vendor manifest: %w", err)
+			}
+			resources[key] = resource
+		}
+	}
+
+	synced := &VendorManifest{Version: VendorManifestVersion, Files: make(map[string]*VendoredFile)}
+	if len(paths) > 0 {
+		for key, f := range m.Files {
+			synced.Files[key] = f
+		}
+	}
+	for key, resource := range resources {
+		if _, err := r.vendorFile(ctx, synced, key, resource); err != nil {
+			return fmt.Errorf("error vendoring %s: %w", key, err)
+		}
+		if f, ok := m.Files[key]; ok && f.Commit != synced.Files[key].Commit {
+			log.Infof("vendored %s updated from %s to %s", key, f.Commit, synced.Files[key].Commit)
+		}
+	}
+	if err := synced.save(r.vendorDir); err != nil {
+		return err
+	}
+	r.vendor = synced
+	if len(paths) > 0 {
+		return r.removeSuperseded(m, synced, resources)
+	}
+	_, err = r.prune(synced)
+	return err
+}
+
+// removeSuperseded removes the files of the resources recorded by the old manifest that the synced manifest no longer
+// references (and any directories left empty).
+func (r *RemoteFs) removeSuperseded(old, synced *VendorManifest, resources map[string]*retriever.Resource) error {
+	referenced := make(map[string]bool)
+	for _, f := range synced.Files {
+		referenced[f.Path] = true
+	}
+	for key := range resources {
+		f, ok := old.Files[key]
+		if !ok || referenced[f.Path] || !filepath.IsLocal(filepath.FromSlash(f.Path)) {
+			continue
+		}
+		log.Debugf("removing superseded vendored file: %s", f.Path)
+		if err := os.Remove(filepath.Join(r.vendorDir, filepath.FromSlash(f.Path))); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return removeEmptyDirs(r.vendorDir)
+}
+
+// VendorVerify checks that the vendor directory is in sync with its manifest (e.g. in CI), i.e. that every file in the
+// manifest is vendored with the content recorded and that no other files are vendored. A *VendorError describes
+// every problem found.
+func (r *RemoteFs) VendorVerify() error {
+	r.vendorMu.Lock()
+	defer r.vendorMu.Unlock()
+	m, err := r.manifest()
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	for _, key := range sortedKeys(m.Files) {
+		f := m.Files[key]
+		b, err := os.ReadFile(filepath.Join(r.vendorDir, filepath.FromSlash(f.Path)))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			problems = append(problems, fmt.Sprintf("%s: missing vendored file %s", key, f.Path))
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+		case contentSum(b) != f.Sum:
+			problems = append(problems, fmt.Sprintf("%s: vendored file %s has hash %s, expected %s", key, f.Path, contentSum(b), f.Sum))
+		}
+	}
+	unreferenced, err := r.unreferenced(m)
+	if err != nil {
+		return err
+	}
+	for _, p := range unreferenced {
+		problems = append(problems, fmt.Sprintf("%s: not in the vendor manifest", p))
+	}
+	if len(problems) > 0 {
+		return &VendorError{Dir: r.vendorDir, Problems: problems}
+	}
+	return nil
+}
+
+// VendorPrune removes the files of the vendor directory that aren't referenced by its manifest (and any directories
+// left empty), returning the paths of the files removed.
+func (r *RemoteFs) VendorPrune() ([]string, error) {
+	r.vendorMu.Lock()
+	defer r.vendorMu.Unlock()
+	m, err := r.manifest()
+	if err != nil {
+		return nil, err
+	}
+	return r.prune(m)
+}
+
+func (r *RemoteFs) prune(m *VendorManifest) ([]string, error) {
+	unreferenced, err := r.unreferenced(m)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range unreferenced {
+		log.Debugf("pruning vendored file: %s", p)
+		if err := os.Remove(filepath.Join(r.vendorDir, filepath.FromSlash(p))); err != nil {
+			return nil, err
+		}
+	}
+	return unreferenced, removeEmptyDirs(r.vendorDir)
+}
+
+// unreferenced returns the paths of the files of the vendor directory that aren't referenced by the manifest.
+func (r *RemoteFs) unreferenced(m *VendorManifest) ([]string, error) {
+	referenced := map[string]bool{VendorManifestFile: true}
+	for _, f := range m.Files {
+		referenced[f.Path] = true
+	}
+	var unreferenced []string
+	err := filepath.WalkDir(r.vendorDir, func(p string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && p == r.vendorDir {
+			return filepath.SkipDir
+		} else if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(r.vendorDir, p)
+		if err != nil {
+			return err
+		}
+		if rel = filepath.ToSlash(rel); !referenced[rel] {
+			unreferenced = append(unreferenced, rel)
+		}
+		return nil
+	})
+	return unreferenced, err
+}
+
+// removeEmptyDirs removes the empty directories within the directory (but not the directory itself).
+func removeEmptyDirs(dir string) error {
+	var dirs []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && p == dir {
+			return filepath.SkipDir
+		} else if err != nil {
+			return err
+		}
+		if d.IsDir() && p != dir {
+			dirs = append(dirs, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Remove the deepest directories first, so that their parents may be empty once they are removed.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if entries, err := os.ReadDir(dirs[i]); err == nil && len(entries) == 0 {
+			if err := os.Remove(dirs[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func sortedKeys(files map[string]*VendoredFile) []string {
+	keys := make([]string, 0, len(files))
+	for k := range files {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,132 @@
+package remotefs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anz-bank/golden-retriever/reader/filesystem"
+	"github.com/anz-bank/golden-retriever/retriever"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// branchRetriever retrieves the content of every file from the commit of a branch, which can be moved.
+type branchRetriever struct {
+	hash    retriever.Hash
+	content string
+	count   int
+}
+
+func (b *branchRetriever) Retrieve(ctx context.Context, resource *retriever.Resource) ([]byte, error) {
+	result, err := b.RetrieveWithInfo(ctx, resource)
+	if err != nil {
+		return nil, err
+	}
+	return result.Content, nil
+}
+
+func (b *branchRetriever) RetrieveWithInfo(_ context.Context, resource *retriever.Resource) (*retriever.Result, error) {
+	if b.hash.IsZero() {
+		return nil, errors.New("offline")
+	}
+	b.count++
+	return &retriever.Result{Content: []byte(b.content + resource.Filepath), Hash: b.hash, Ref: resource.Ref.Name(), Remote: resource.Repo}, nil
+}
+
+func TestVendor(t *testing.T) {
+	h1, err := retriever.NewHash("133416d690dbffc8fe321e12bdd4f21d79e2a479")
+	require.NoError(t, err)
+	h2, err := retriever.NewHash("233416d690dbffc8fe321e12bdd4f21d79e2a479")
+	require.NoError(t, err)
+	h3, err := retriever.NewHash("333416d690dbffc8fe321e12bdd4f21d79e2a479")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	vendor := t.TempDir()
+	retr := &branchRetriever{hash: h1, content: "v1:"}
+	r := New(filesystem.New(afero.NewMemMapFs()), retr)
+	r.Vendor(vendor)
+
+	b, h, ref, err := r.ReadHashBranch(ctx, "github.com/foo/bar/a.yaml@main")
+	require.NoError(t, err)
+	require.Equal(t, "v1:a.yaml", string(b))
+	require.Equal(t, h1, h)
+	require.Equal(t, "main", ref)
+
+	m, err := ReadVendorManifest(vendor)
+	require.NoError(t, err)
+	require.Equal(t, map[string]*VendoredFile{"git+https://github.com/foo/bar//a.yaml@main": {
+		Ref: "main", Commit: h1.String(), Path: "github.com/foo/bar/a.yaml@" + h1.String(), Sum: contentSum([]byte("v1:a.yaml")),
+	}}, m.Files)
+
+	// Vendored files are read from the vendor directory, even if their branch moves.
+	retr.hash, retr.content = h2, "v2:"
+	b, h, _, err = r.ReadHashBranch(ctx, "//github.com/foo/bar/a.yaml@main")
+	require.NoError(t, err)
+	require.Equal(t, "v1:a.yaml", string(b))
+	require.Equal(t, h1, h)
+	require.Equal(t, 1, retr.count)
+	require.NoError(t, r.VendorVerify())
+
+	// Files not in the manifest, or whose content differs from it, fail verification.
+	stray := filepath.Join(vendor, "github.com", "foo", "old.yaml@"+h1.String())
+	require.NoError(t, os.WriteFile(stray, []byte("old"), 0644))
+	err = r.VendorVerify()
+	var vendorErr *VendorError
+	require.ErrorAs(t, err, &vendorErr)
+	require.Equal(t, []string{"github.com/foo/old.yaml@" + h1.String() + ": not in the vendor manifest"}, vendorErr.Problems)
+	require.NoError(t, os.WriteFile(filepath.Join(vendor, "github.com/foo/bar/a.yaml@"+h1.String()), []byte("changed"), 0644))
+	require.ErrorAs(t, r.VendorVerify(), &vendorErr)
+	require.Len(t, vendorErr.Problems, 2)
+
+	// Syncing refreshes the files of branches and prunes those no longer referenced.
+	require.NoError(t, r.VendorSync(ctx))
+	require.NoError(t, r.VendorVerify())
+	b, h, _, err = r.ReadHashBranch(ctx, "github.com/foo/bar/a.yaml@main")
+	require.NoError(t, err)
+	require.Equal(t, "v2:a.yaml", string(b))
+	require.Equal(t, h2, h)
+	require.NoFileExists(t, stray)
+	require.NoFileExists(t, filepath.Join(vendor, "github.com/foo/bar/a.yaml@"+h1.String()))
+	require.FileExists(t, filepath.Join(vendor, "github.com/foo/bar/a.yaml@"+h2.String()))
+
+	// Syncing paths re-vendors only those paths, pruning only the files they superseded.
+	_, _, _, err = r.ReadHashBranch(ctx, "github.com/foo/bar/b.yaml@main")
+	require.NoError(t, err)
+	retr.hash, retr.content = h3, "v3:"
+	require.NoError(t, os.WriteFile(stray, []byte("old"), 0644))
+	require.NoError(t, r.VendorSync(ctx, "git+https://github.com/foo/bar//b.yaml@main#L1"))
+	m, err = ReadVendorManifest(vendor)
+	require.NoError(t, err)
+	require.Len(t, m.Files, 2)
+	require.Equal(t, h2.String(), m.Files["git+https://github.com/foo/bar//a.yaml@main"].Commit)
+	require.Equal(t, h3.String(), m.Files["git+https://github.com/foo/bar//b.yaml@main"].Commit)
+	require.FileExists(t, filepath.Join(vendor, "github.com/foo/bar/a.yaml@"+h2.String()))
+	require.FileExists(t, filepath.Join(vendor, "github.com/foo/bar/b.yaml@"+h3.String()))
+	require.NoFileExists(t, filepath.Join(vendor, "github.com/foo/bar/b.yaml@"+h2.String()))
+	require.FileExists(t, stray)
+	require.NoError(t, os.Remove(stray))
+
+	// Vendored files are read without retrieving them.
+	offline := New(filesystem.New(afero.NewMemMapFs()), &branchRetriever{})
+	offline.Vendor(vendor)
+	b, h, _, err = offline.ReadHashBranch(ctx, "github.com/foo/bar/b.yaml@main")
+	require.NoError(t, err)
+	require.Equal(t, "v3:b.yaml", string(b))
+	require.Equal(t, h3, h)
+	b, h, _, err = offline.ReadHashBranch(ctx, "github.com/foo/bar/a.yaml@main")
+	require.NoError(t, err)
+	require.Equal(t, "v2:a.yaml", string(b))
+	require.Equal(t, h2, h)
+	_, _, _, err = offline.ReadHashBranch(ctx, "github.com/foo/bar/c.yaml@main")
+	require.Error(t, err)
+
+	require.NoError(t, os.WriteFile(stray, []byte("old"), 0644))
+	pruned, err := offline.VendorPrune()
+	require.NoError(t, err)
+	require.Equal(t, []string{"github.com/foo/old.yaml@" + h1.String()}, pruned)
+	require.NoDirExists(t, filepath.Dir(stray)+"/bar/a.yaml@"+h1.String())
+}
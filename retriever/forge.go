@@ -0,0 +1,134 @@
+package retriever
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Hosts of forges whose web URLs are parsed by host rather than by shape.
+const (
+	githubHost    = "github.com"
+	githubRawHost = "raw.githubusercontent.com"
+	bitbucketHost = "bitbucket.org"
+)
+
+var (
+	// forgeLinesRegexps match the fragments selecting lines of files on the web pages of forges, e.g. L10-L20 (GitHub and
+	// Gitea), L10-20 (GitLab) and lines-10:20 (Bitbucket).
+	forgeLinesRegexps = []*regexp.Regexp{
+		regexp.MustCompile(`^L([1-9][0-9]*)(?:-L?([1-9][0-9]*))?$`),
+		regexp.MustCompile(`^lines-([1-9][0-9]*)(?::([1-9][0-9]*))?$`),
+	}
+)
+
+// IsWebURL reports whether the string is the URL of a file (or directory) on the web pages of a forge, as parsed by
+// ParseWebURL.
+func IsWebURL(str string) bool {
+	_, err := ParseWebURL(str)
+	return err == nil
+}
+
+// ParseWebURL parses the URL of a file (or directory) on the web pages of a forge, returning the corresponding
+// resource. The following shapes of URL are recognised (on any host, e.g. for self-hosted instances):
+//
+//	GitHub:    https://github.com/org/repo/{blob,raw,tree}/ref/path
+//	           https://raw.githubusercontent.com/org/repo/ref/path
+//	GitLab:    https://gitlab.com/group/[subgroup/...]repo/-/{blob,raw,tree}/ref/path
+//	Bitbucket: https://bitbucket.org/workspace/repo/{src,raw}/ref/path
+//	Gitea:     https://gitea.com/owner/repo/{src,raw}/{branch,tag,commit}/ref/path
+//
+// Fragments selecting lines (e.g. #L10-L20, #L10-20 or #lines-10:20) set the lines of the resource, other fragments
+// and queries are ignored.
+//
+// Note: Web URLs don't separate the reference from the path, so references are assumed to be a single segment (e.g.
+// main or v1.0.0), or three segments if fully qualified (e.g. refs/heads/main).
+func ParseWebURL(str string) (*Resource, error) {
+	u, err := url.Parse(str)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("%s is not a web URL of a forge: scheme must be https or http", str)
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	gitlab := slices.Index(segments, "-") // GitLab separates the repository from the kind of page with a - segment.
+
+	var repo, rest []string
+	switch {
+	case u.Host == githubRawHost:
+		if len(segments) < 3 {
+			return nil, fmt.Errorf("%s is not a web URL of a forge: missing repository or reference", str)
+		}
+		repo, rest = segments[:2], segments[2:]
+		u.Host = githubHost
+	case gitlab > 1 && gitlab+1 < len(segments) && isOneOf(segments[gitlab+1], "blob", "raw", "tree"):
+		repo, rest = segments[:gitlab], segments[gitlab+2:]
+	case len(segments) > 3 && isOneOf(segments[2], "src", "raw") && isOneOf(segments[3], "branch", "tag", "commit") &&
+		u.Host != githubHost && u.Host != bitbucketHost:
+		repo, rest = segments[:2], segments[4:]
+	case len(segments) > 2 && isOneOf(segments[2], "blob", "raw", "tree", "src"):
+		repo, rest = segments[:2], segments[3:]
+	default:
+		return nil, fmt.Errorf("%s is not a web URL of a forge: unrecognised shape of URL", str)
+	}
+
+	ref, path := splitWebRef(rest)
+	if ref == "" {
+		return nil, fmt.Errorf("%s is not a web URL of a forge: missing reference", str)
+	}
+	r, err := ParseReference(ref)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a web URL of a forge: %w", str, err)
+	}
+	resource := &Resource{Repo: u.Host + "/" + strings.Join(repo, "/"), Filepath: path, Ref: r}
+	if err := validateURIRepo(URISchemeHTTPS, resource.Repo); err != nil {
+		return nil, fmt.Errorf("%s is not a web URL of a forge: %w", str, err)
+	}
+	if err := validateURIPath(resource.Filepath, true); err != nil {
+		return nil, fmt.Errorf("%s is not a web URL of a forge: invalid file path %q: %w", str, resource.Filepath, err)
+	}
+	resource.Lines = parseWebLines(u.Fragment)
+	return resource, nil
+}
+
+// splitWebRef splits the segments following the marker of a web URL into the reference and the path of the file.
+func splitWebRef(segments []string) (string, string) {
+	n := 1
+	if len(segments) > 2 && segments[0] == "refs" && isOneOf(segments[1], "heads", "tags") {
+		n = 3
+	}
+	if len(segments) < n {
+		return "", ""
+	}
+	return strings.Join(segments[:n], "/"), strings.Join(segments[n:], "/")
+}
+
+// parseWebLines returns the lines selected by the fragment of a web URL, or the entire file if it doesn't select any.
+func parseWebLines(fragment string) LineRange {
+	for _, re := range forgeLinesRegexps {
+		m := re.FindStringSubmatch(fragment)
+		if m == nil {
+			continue
+		}
+		start, err := strconv.Atoi(m[1])
+		if err != nil {
+			return LineRange{}
+		}
+		end := start
+		if m[2] != "" {
+			if end, err = strconv.Atoi(m[2]); err != nil || end < start {
+				return LineRange{}
+			}
+		}
+		return LineRange{Start: start, End: end}
+	}
+	return LineRange{}
+}
+
+func isOneOf(s string, values ...string) bool {
+	return slices.Contains(values, s)
+}
@@ -0,0 +1,64 @@
+package retriever
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWebURL(t *testing.T) {
+	h, err := NewHash("1e7c4cecaaa8f76e3c668cebc411f1b03171501f")
+	require.NoError(t, err)
+	hash, err := NewHashReference(h)
+	require.NoError(t, err)
+	main := NewSymbolicReference("main")
+	v1 := NewSymbolicReference("v1")
+
+	for url, resource := range map[string]*Resource{
+		// GitHub
+		"https://github.com/org/repo/blob/main/api/spec.yaml":           {Repo: "github.com/org/repo", Filepath: "api/spec.yaml", Ref: main},
+		"https://github.com/org/repo/blob/main/api/spec.yaml#L10-L20":   {Repo: "github.com/org/repo", Filepath: "api/spec.yaml", Ref: main, Lines: LineRange{Start: 10, End: 20}},
+		"https://github.com/org/repo/raw/v1/api/spec.yaml?raw=true":     {Repo: "github.com/org/repo", Filepath: "api/spec.yaml", Ref: v1},
+		"https://github.com/org/repo/tree/main/api":                     {Repo: "github.com/org/repo", Filepath: "api", Ref: main},
+		"https://raw.githubusercontent.com/org/repo/main/api/spec.yaml": {Repo: "github.com/org/repo", Filepath: "api/spec.yaml", Ref: main},
+		"https://raw.githubusercontent.com/org/repo/refs/heads/main/spec.yaml": {
+			Repo: "github.com/org/repo", Filepath: "spec.yaml", Ref: NewSymbolicReference("refs/heads/main")},
+		"https://github.example.com/org/repo/blob/1e7c4cecaaa8f76e3c668cebc411f1b03171501f/spec.yaml#L7": {
+			Repo: "github.example.com/org/repo", Filepath: "spec.yaml", Ref: hash, Lines: LineRange{Start: 7, End: 7}},
+		// GitLab
+		"https://gitlab.com/group/sub/repo/-/blob/main/api/spec.yaml#L10-20": {
+			Repo: "gitlab.com/group/sub/repo", Filepath: "api/spec.yaml", Ref: main, Lines: LineRange{Start: 10, End: 20}},
+		"https://gitlab.example.com:8443/group/repo/-/raw/v1/file?ref_type=tags": {
+			Repo: "gitlab.example.com:8443/group/repo", Filepath: "file", Ref: v1},
+		"https://gitlab.com/group/repo/-/tree/main": {Repo: "gitlab.com/group/repo", Ref: main},
+		// Bitbucket
+		"https://bitbucket.org/workspace/repo/src/main/api/spec.yaml#lines-10:20": {
+			Repo: "bitbucket.org/workspace/repo", Filepath: "api/spec.yaml", Ref: main, Lines: LineRange{Start: 10, End: 20}},
+		"https://bitbucket.org/workspace/repo/raw/v1/api/spec.yaml": {Repo: "bitbucket.org/workspace/repo", Filepath: "api/spec.yaml", Ref: v1},
+		// Gitea
+		"https://gitea.com/owner/repo/src/branch/main/api/spec.yaml#L10-L20": {
+			Repo: "gitea.com/owner/repo", Filepath: "api/spec.yaml", Ref: main, Lines: LineRange{Start: 10, End: 20}},
+		"https://gitea.com/owner/repo/raw/tag/v1/api/spec.yaml": {Repo: "gitea.com/owner/repo", Filepath: "api/spec.yaml", Ref: v1},
+		"https://codeberg.org/owner/repo/src/commit/1e7c4cecaaa8f76e3c668cebc411f1b03171501f/spec.yaml": {
+			Repo: "codeberg.org/owner/repo", Filepath: "spec.yaml", Ref: hash},
+	} {
+		t.Run(url, func(t *testing.T) {
+			require.True(t, IsWebURL(url))
+			r, err := ParseWebURL(url)
+			require.NoError(t, err)
+			require.Equal(t, resource, r)
+		})
+	}
+
+	for _, invalid := range []string{
+		"github.com/org/repo/blob/main/api/spec.yaml",
+		"git+https://github.com/org/repo//api/spec.yaml",
+		"https://github.com/org/repo",
+		"https://github.com/org/repo/blob",
+		"https://github.com/org/repo/issues/1",
+		"https://gitlab.com/group/repo/-/issues/1",
+		"https://raw.githubusercontent.com/org/repo",
+	} {
+		require.False(t, IsWebURL(invalid), invalid)
+	}
+}
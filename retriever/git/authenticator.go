@@ -129,6 +129,10 @@ type Credential struct {
 	Password string
 }
 
+// tokenUsername is the username of the credentials of tokens (e.g. of AuthOptions.WithTokens), whose password is the
+// token.
+const tokenUsername = "modv2"
+
 // SSHKey represents a pair of SSH private key and key password.
 type SSHKey struct {
 	PrivateKey         string
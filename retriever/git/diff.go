@@ -0,0 +1,239 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/anz-bank/golden-retriever/retriever"
+)
+
+// DiffOpts describes how to compare two references of a repository.
+type DiffOpts struct {
+	Fetch OptFetch // How to fetch (or not) the references from the remote repository.
+	Paths []string // The files or directories to compare (e.g. api or api/spec.yaml), or all if empty.
+	Patch bool     // True to include unified diffs of the text of the changed files.
+}
+
+func (o DiffOpts) String() string {
+	return fmt.Sprintf("{Fetch:%v, Paths:%v, Patch:%v}", o.Fetch, o.Paths, o.Patch)
+}
+
+// Diff is the difference between the commits of two references of a repository.
+type Diff struct {
+	From  retriever.Hash // The hash of the commit compared from.
+	To    retriever.Hash // The hash of the commit compared to.
+	Files []FileDiff     // The changed files, sorted by path.
+}
+
+// FileDiff is a file changed between two commits.
+type FileDiff struct {
+	Path    string     // The path of the file (within the commit compared from, if deleted).
+	OldPath string     // The path of the file within the commit compared from, if renamed.
+	Status  DiffStatus // How the file changed.
+	Binary  bool       // Whether the file is binary (if a patch was requested).
+	Patch   string     // The unified diff of the text of the file (if requested and not binary).
+}
+
+// DiffStatus describes how a file changed between two commits.
+type DiffStatus int
+
+const (
+	DiffStatusModified DiffStatus = iota // The file was modified.
+	DiffStatusAdded                      // The file was added.
+	DiffStatusDeleted                    // The file was deleted.
+	DiffStatusRenamed                    // The file was renamed (and possibly modified).
+)
+
+func (s DiffStatus) String() string {
+	switch s {
+	case DiffStatusModified:
+		return "modified"
+	case DiffStatusAdded:
+		return "added"
+	case DiffStatusDeleted:
+		return "deleted"
+	case DiffStatusRenamed:
+		return "renamed"
+	default:
+		return "-"
+	}
+}
+
+// Diff compares the commits of two references (e.g. branches, tags or hashes) of the repository, returning the changed
+// files. The files are compared within the object store, so the repository isn't checked out.
+func (a Git) Diff(ctx context.Context, repo, from, to string, opts DiffOpts) (*Diff, error) {
+	log.Debugf("comparing repo: %v from: %v to: %v with opts: %v", repo, from, to, opts)
+	opts.Fetch = a.fetchOpt(opts.Fetch)
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+	a.once.Wait(repo)
+	defer a.once.Unregister(repo)
+
+	r, ok := a.cacher.Get(repo)
+	if !ok {
+		head := &retriever.Resource{Repo: repo, Ref: retriever.HEADReference()}
+		var err error
+		r, err = a.CloneWithOpts(ctx, head, CloneOpts{Depth: 1, NoCheckout: true, Partial: a.partialClone})
+		if err != nil {
+			return nil, fmt.Errorf("error cloning repository: %v: %w", repo, err)
+		}
+	}
+
+	fromCommit, err := a.diffCommit(ctx, r, repo, from, opts.Fetch)
+	if err != nil {
+		return nil, err
+	}
+	toCommit, err := a.diffCommit(ctx, r, repo, to, opts.Fetch)
+	if err != nil {
+		return nil, err
+	}
+	return a.diffCommits(ctx, r, repo, fromCommit, toCommit, opts)
+}
+
+// diffCommit returns the commit of the reference, fetching it as required. Symbolic references are resolved via the
+// remote repository when fetching.
+func (a Git) diffCommit(ctx context.Context, r *git.Repository, repo, ref string, fetch OptFetch) (*object.Commit, error) {
+	rr, err := retriever.ParseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+	if rr.IsShortHash() && a.isRefName(ctx, r, repo, ref) {
+		rr = retriever.NewSymbolicReference(ref)
+	}
+	resource := &retriever.Resource{Repo: repo, Ref: rr}
+	if rr.IsShortHash() {
+		if err := a.ResolveShortHash(ctx, r, resource); err != nil {
+			return nil, err
+		}
+	}
+
+	if !rr.IsHash() && fetch != OptFetchTrue {
+		if ref, err := a.Resolve(r, rr); err == nil {
+			rr, resource.Ref = ref, ref
+		}
+	}
+	if !rr.IsHash() {
+		if fetch == OptFetchFalse {
+			return nil, fmt.Errorf("reference %s not found and fetch was explicitly false", ref)
+		}
+		remote, err := a.ResolveRemote(ctx, repo, rr.Name())
+		if err != nil {
+			return nil, err
+		}
+		if err := rr.SetHash(remote.Hash); err != nil {
+			return nil, err
+		}
+	}
+	if fetch != OptFetchFalse {
+		if err := a.Fetch(ctx, r, resource); err != nil {
+			return nil, fmt.Errorf("error fetching reference: %v: %w", ref, err)
+		}
+	}
+
+	commit, err := r.CommitObject(plumbing.NewHash(rr.Hash().String()))
+	if err != nil {
+		return nil, fmt.Errorf("error resolving commit of reference: %v: %w", ref, err)
+	}
+	return commit, nil
+}
+
+func (a Git) diffCommits(ctx context.Context, r *git.Repository, repo string, from, to *object.Commit, opts DiffOpts) (*Diff, error) {
+	fromTree, err := from.Tree()
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := to.Tree()
+	if err != nil {
+		return nil, err
+	}
+	diffOpts := *object.DefaultDiffTreeOptions
+	if IsPartial(r) {
+		diffOpts.OnlyExactRenames = true // Detecting modified renames requires the content of every added and deleted file.
+	}
+	changes, err := object.DiffTreeWithOptions(ctx, fromTree, toTree, &diffOpts)
+	if err != nil {
+		return nil, fmt.Errorf("error comparing commits: %v and %v: %w", from.Hash, to.Hash, err)
+	}
+
+	d := &Diff{}
+	if d.From, err = retriever.NewHash(from.Hash.String()); err != nil {
+		return nil, err
+	}
+	if d.To, err = retriever.NewHash(to.Hash.String()); err != nil {
+		return nil, err
+	}
+	for _, c := range changes {
+		if !matchesPaths(opts.Paths, c.From.Name) && !matchesPaths(opts.Paths, c.To.Name) {
+			continue
+		}
+		action, err := c.Action()
+		if err != nil {
+			return nil, err
+		}
+		f := FileDiff{Path: c.To.Name}
+		switch {
+		case action == merkletrie.Insert:
+			f.Status = DiffStatusAdded
+		case action == merkletrie.Delete:
+			f.Path, f.Status = c.From.Name, DiffStatusDeleted
+		case c.From.Name != c.To.Name:
+			f.OldPath, f.Status = c.From.Name, DiffStatusRenamed
+		default:
+			f.Status = DiffStatusModified
+		}
+
+		if opts.Patch {
+			if c.From.Name != "" {
+				if err := a.showPartial(ctx, r, repo, from, c.From.Name); err != nil {
+					return nil, err
+				}
+			}
+			if c.To.Name != "" {
+				if err := a.showPartial(ctx, r, repo, to, c.To.Name); err != nil {
+					return nil, err
+				}
+			}
+			patch, err := c.PatchContext(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("error comparing file: %v: %w", f.Path, err)
+			}
+			for _, fp := range patch.FilePatches() {
+				f.Binary = f.Binary || fp.IsBinary()
+			}
+			if !f.Binary {
+				f.Patch = patch.String()
+			}
+		}
+		d.Files = append(d.Files, f)
+	}
+	slices.SortFunc(d.Files, func(x, y FileDiff) int { return strings.Compare(x.Path, y.Path) })
+	return d, nil
+}
+
+// matchesPaths reports whether the file is one of (or within one of) the paths, or if there are no paths.
+func matchesPaths(paths []string, file string) bool {
+	if file == "" {
+		return false
+	}
+	if len(paths) == 0 {
+		return true
+	}
+	for _, p := range paths {
+		p = strings.Trim(p, "/")
+		if p == "" || file == p || strings.HasPrefix(file, p+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitDiff(t *testing.T) {
+	dir, hashes := initLocalRepo(t, "v1\n")
+	commitLocalRepo(t, dir, "api/spec.yaml", "a\nb\n")
+	commitLocalRepo(t, dir, "api/old.yaml", "old\n")
+	require.NoError(t, execute(dir, "git", "tag", "v1.0.0"))
+	commitLocalRepo(t, dir, "api/spec.yaml", "a\nc\n")
+	require.NoError(t, execute(dir, "git", "mv", "api/old.yaml", "api/new.yaml"))
+	require.NoError(t, os.Remove(filepath.Join(dir, "README.md")))
+	hashes = append(hashes, commitLocalRepo(t, dir, "docs/guide.md", "guide\n"))
+	require.NoError(t, execute(dir, "git", "config", "uploadpack.allowReachableSHA1InWant", "true"))
+
+	g := NewWithCache(&AuthOptions{Local: true}, NewPlainFscache(t.TempDir()))
+	ctx := context.Background()
+
+	d, err := g.Diff(ctx, dir, "v1.0.0", "main", DiffOpts{})
+	require.NoError(t, err)
+	require.Equal(t, revParse(t, dir, "v1.0.0"), d.From.String())
+	require.Equal(t, hashes[1], d.To.String())
+	require.Equal(t, []FileDiff{
+		{Path: "README.md", Status: DiffStatusDeleted},
+		{Path: "api/new.yaml", OldPath: "api/old.yaml", Status: DiffStatusRenamed},
+		{Path: "api/spec.yaml", Status: DiffStatusModified},
+		{Path: "docs/guide.md", Status: DiffStatusAdded},
+	}, d.Files)
+
+	d, err = g.Diff(ctx, dir, "v1.0.0", hashes[1], DiffOpts{Paths: []string{"api/spec.yaml"}, Patch: true, Fetch: OptFetchUnknown})
+	require.NoError(t, err)
+	require.Len(t, d.Files, 1)
+	require.Contains(t, d.Files[0].Patch, "--- a/api/spec.yaml\n+++ b/api/spec.yaml\n")
+	require.Contains(t, d.Files[0].Patch, " a\n-b\n+c\n")
+
+	d, err = g.Diff(ctx, dir, hashes[0], "v1.0.0", DiffOpts{Paths: []string{"api/"}, Fetch: OptFetchUnknown})
+	require.NoError(t, err)
+	require.Equal(t, []FileDiff{
+		{Path: "api/old.yaml", Status: DiffStatusAdded},
+		{Path: "api/spec.yaml", Status: DiffStatusAdded},
+	}, d.Files)
+
+	d, err = g.Diff(ctx, dir, hashes[0], "v1.0.0", DiffOpts{Paths: []string{"README.md"}, Fetch: OptFetchFalse})
+	require.NoError(t, err)
+	require.Empty(t, d.Files)
+
+	_, err = g.Diff(ctx, dir, "missing", "main", DiffOpts{Fetch: OptFetchFalse})
+	require.ErrorContains(t, err, "reference missing not found")
+}
+
+func TestGitDiff_Partial(t *testing.T) {
+	dir, hashes := partialRepo(t, true)
+	commitLocalRepo(t, dir, "data/large.bin", "larger content")
+	g := NewWithOptions(&NewGitOptions{AuthOptions: &AuthOptions{Local: true}, Cacher: NewPlainFscache(t.TempDir()), PartialClone: true})
+
+	d, err := g.Diff(context.Background(), dir, hashes[1], "HEAD", DiffOpts{Patch: true})
+	require.NoError(t, err)
+	require.Len(t, d.Files, 1)
+	require.Equal(t, DiffStatusModified, d.Files[0].Status)
+	require.Contains(t, d.Files[0].Patch, "-large content\n\\ No newline at end of file\n+larger content\n")
+}
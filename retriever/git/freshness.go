@@ -0,0 +1,130 @@
+package git
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/anz-bank/golden-retriever/retriever"
+)
+
+// FreshnessPolicy describes how long fetched references are fresh for, i.e. are retrieved without being fetched again.
+// Tags and hashes are immutable, so are always fresh once fetched.
+//
+// The times references are fetched at are persisted within repositories cached in the filesystem (FsCache and
+// PlainFsCache), so that they remain fresh across processes.
+type FreshnessPolicy struct {
+	// How long branches are fresh for. Zero (the default) keeps them fresh for the lifetime of the Git (or forever if
+	// NoForcedFetch is set and they are cached), and a negative duration fetches them on every retrieval.
+	Branch time.Duration
+
+	// How long the HEAD of repositories is fresh for, as for Branch.
+	HEAD time.Duration
+}
+
+// fetchTimesFile is the name of the file within the git directory of cached repositories recording the times their
+// references were last fetched at.
+const fetchTimesFile = "golden-retriever-fetched.json"
+
+// fetchTimesPath returns the path of the file recording the fetch times of the references of the repository, or false
+// if the repository isn't cached in the filesystem.
+func (a Git) fetchTimesPath(repo string) (string, bool) {
+	switch c := a.cacher.(type) {
+	case PlainFsCache:
+		return filepath.Join(c.RepoDir(repo), ".git", fetchTimesFile), true
+	case FsCache:
+		return filepath.Join(c.repoDir(repo), fetchTimesFile), true
+	default:
+		return "", false
+	}
+}
+
+// readFetchTimes returns the persisted times the references of the repository were last fetched at, keyed by name.
+func (a Git) readFetchTimes(repo string) (map[string]time.Time, error) {
+	times := map[string]time.Time{}
+	path, ok := a.fetchTimesPath(repo)
+	if !ok {
+		return times, nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return times, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &times); err != nil {
+		return nil, err
+	}
+	return times, nil
+}
+
+// writeFetchTimes persists the time the named references of the repository were fetched at.
+func (a Git) writeFetchTimes(repo string, names []string, t time.Time) error {
+	path, ok := a.fetchTimesPath(repo)
+	if !ok || len(names) == 0 {
+		return nil
+	}
+	times, err := a.readFetchTimes(repo)
+	if err != nil {
+		times = map[string]time.Time{} // Replace the unreadable file.
+	}
+	for _, name := range names {
+		times[name] = t
+	}
+	b, err := json.MarshalIndent(times, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+// fetchedAt returns the time the reference of the resource was last fetched at, either by this Git or as persisted
+// within the cached repository.
+func (a Git) fetchedAt(resource *retriever.Resource) (time.Time, bool) {
+	if t, ok := a.fetchedRefs.Load(keyFromResource(resource)); ok {
+		return t.(time.Time), true
+	}
+	times, err := a.readFetchTimes(resource.Repo)
+	if err != nil {
+		log.Debugf("error reading fetch times of repo: %v: %s", resource.Repo, err)
+		return time.Time{}, false
+	}
+	t, ok := times[resource.Ref.Name()]
+	return t, ok
+}
+
+// ttl returns how long the reference of the resource (the HEAD of its repository if head is true) is fresh for once
+// fetched, or zero if it is fresh for the lifetime of the Git.
+func (a Git) ttl(resource *retriever.Resource, head bool) time.Duration {
+	switch {
+	case resource.Ref.IsHash():
+		return 0
+	case head:
+		return a.freshness.HEAD
+	default:
+		return a.freshness.Branch
+	}
+}
+
+// isFresh reports whether the reference of the resource (the HEAD of its repository if head is true) has been fetched
+// recently enough per the freshness policy that it needn't be fetched again. Tags known to the repository are always
+// fresh.
+func (a Git) isFresh(r *git.Repository, resource *retriever.Resource, head bool) bool {
+	ttl := a.ttl(resource, head)
+	switch {
+	case ttl == 0:
+		return a.isFetched(resource)
+	case ttl < 0:
+		return false
+	}
+	if _, ok := a.ResolveTag(r, resource.Ref); ok {
+		return true
+	}
+	t, ok := a.fetchedAt(resource)
+	return ok && time.Since(t) < ttl
+}
@@ -0,0 +1,64 @@
+package git
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/anz-bank/golden-retriever/retriever"
+)
+
+func TestGitFreshness(t *testing.T) {
+	dir, hashes := initLocalRepo(t, "v1")
+	cache := NewPlainFscache(t.TempDir())
+	ctx := context.Background()
+	main := &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: retriever.NewBranchReference("main")}
+	head := &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: retriever.HEADReference()}
+	newGit := func(noForcedFetch bool, policy FreshnessPolicy) *Git {
+		return NewWithOptions(&NewGitOptions{AuthOptions: &AuthOptions{Local: true}, Cacher: cache, NoForcedFetch: noForcedFetch, Freshness: policy})
+	}
+	retrieve := func(g *Git, resource *retriever.Resource) string {
+		result, err := g.RetrieveWithInfo(ctx, resource)
+		require.NoError(t, err)
+		return result.Hash.String()
+	}
+	timesFile := filepath.Join(cache.RepoDir(dir), ".git", fetchTimesFile)
+	expire := func(name string) {
+		times := map[string]time.Time{}
+		b, err := os.ReadFile(timesFile)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(b, &times))
+		require.Contains(t, times, name)
+		times[name] = times[name].Add(-2 * time.Hour)
+		b, err = json.Marshal(times)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(timesFile, b, 0644))
+	}
+
+	// Branches are fresh until they expire, even across Gits sharing the cache.
+	policy := FreshnessPolicy{Branch: time.Hour}
+	require.Equal(t, hashes[0], retrieve(newGit(false, policy), main))
+	hashes = append(hashes, commitLocalRepo(t, dir, "README.md", "v2"))
+	require.Equal(t, hashes[0], retrieve(newGit(false, policy), main))
+	expire("main")
+	require.Equal(t, hashes[1], retrieve(newGit(false, policy), main))
+
+	// Branches are fetched on every retrieval with a negative duration.
+	g := newGit(false, FreshnessPolicy{Branch: -1, HEAD: -1})
+	hashes = append(hashes, commitLocalRepo(t, dir, "README.md", "v3"))
+	require.Equal(t, hashes[2], retrieve(g, main))
+	hashes = append(hashes, commitLocalRepo(t, dir, "README.md", "v4"))
+	require.Equal(t, hashes[3], retrieve(g, head))
+
+	// Cached branches are retrieved without fetching with NoForcedFetch, until they expire.
+	hashes = append(hashes, commitLocalRepo(t, dir, "README.md", "v5"))
+	require.Equal(t, hashes[3], retrieve(newGit(true, FreshnessPolicy{}), main))
+	require.Equal(t, hashes[3], retrieve(newGit(true, policy), main))
+	expire("main")
+	require.Equal(t, hashes[4], retrieve(newGit(true, policy), main))
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/go-git/go-git/v5"
@@ -42,11 +45,12 @@ type CloneOpts struct {
 	SingleBranch bool // warning do not set this to true if the reference could be a tag
 	NoCheckout   bool
 	Tags         OptTags
+	Partial      bool // fetch commits and trees only, blobs are fetched when shown (implies NoCheckout)
 }
 
 func (o CloneOpts) String() string {
-	return fmt.Sprintf("{Depth:%v, SingleBranch:%v, NoCheckout:%v, Tags:%v}",
-		o.Depth, o.SingleBranch, o.NoCheckout, o.Tags)
+	return fmt.Sprintf("{Depth:%v, SingleBranch:%v, NoCheckout:%v, Tags:%v, Partial:%v}",
+		o.Depth, o.SingleBranch, o.NoCheckout, o.Tags, o.Partial)
 }
 
 // CloneWithOpts clones a repository into the given cache directory using the given options.
@@ -55,6 +59,13 @@ func (a Git) CloneWithOpts(ctx context.Context, resource *retriever.Resource, op
 	repo := resource.Repo
 	c, isPlain := a.cacher.(PlainFsCache)
 
+	if a.offline {
+		return nil, errOffline("cloning", repo)
+	}
+	if opts.Partial {
+		return a.clonePartial(ctx, resource, opts.Depth)
+	}
+
 	if resource.Ref.IsHash() {
 		if isPlain {
 			r, err = git.PlainInit(c.RepoDir(repo), false)
@@ -94,6 +105,7 @@ func (a Git) CloneWithOpts(ctx context.Context, resource *retriever.Resource, op
 				r, err = git.CloneContext(ctx, a.cacher.NewStorer(repo), memfs.New(), options)
 			}
 			if err == nil {
+				a.setServed(repo, meth, url)
 				return r, nil
 			}
 
@@ -127,6 +139,9 @@ func (a Git) CloneWithOpts(ctx context.Context, resource *retriever.Resource, op
 }
 
 func (a Git) Fetch(ctx context.Context, r *git.Repository, resource *retriever.Resource) error {
+	if IsPartial(r) {
+		return a.FetchPartial(ctx, r, resource.Repo, resource.Ref, 1)
+	}
 	if resource.Ref.IsHash() {
 		return a.FetchCommit(ctx, r, resource.Repo, resource.Ref.Hash())
 	}
@@ -202,6 +217,9 @@ func (o FetchOpts) String() string {
 // FetchRefSpec fetches a specific reference specification
 func (a Git) FetchRefSpec(ctx context.Context, r *git.Repository, repo string, spec config.RefSpec, opts FetchOpts) (err error) {
 	log.Debugf("fetching ref spec: %v with opts: %v", spec, opts)
+	if a.offline {
+		return errOffline("fetching", repo)
+	}
 	var tried []string
 
 	logWriter := log.StandardLogger().Writer()
@@ -224,6 +242,7 @@ func (a Git) FetchRefSpec(ctx context.Context, r *git.Repository, repo string, s
 		err = r.FetchContext(ctx, options)
 		if err == nil || errors.Is(err, git.NoErrAlreadyUpToDate) {
 			log.Debugf("ref spec: %v fetched with auth method: %v", spec, meth.Name())
+			a.setServed(repo, meth, url)
 			return nil
 		}
 
@@ -247,6 +266,9 @@ func (a Git) FetchCommitWithOpts(ctx context.Context, r *git.Repository, repo st
 	if err == nil {
 		return nil
 	}
+	if a.offline {
+		return errOffline("fetching", repo)
+	}
 
 	isEmpty := false
 	remotes, err := r.Remotes()
@@ -298,6 +320,7 @@ func (a Git) FetchCommitWithOpts(ctx context.Context, r *git.Repository, repo st
 
 		err = r.FetchContext(ctx, &options)
 		if err == nil || errors.Is(err, git.NoErrAlreadyUpToDate) {
+			a.setServed(repo, meth, url)
 			return nil
 		}
 
@@ -309,30 +332,44 @@ func (a Git) FetchCommitWithOpts(ctx context.Context, r *git.Repository, repo st
 
 // Show the content of a file with given file path and git reference in the cache directory.
 func (a Git) Show(r *git.Repository, resource *retriever.Resource) ([]byte, error) {
-	if !resource.Ref.IsHash() {
-		err := a.ResolveReference(r, resource)
-		if err != nil {
-			return nil, err
-		}
+	return a.ShowContext(context.Background(), r, resource)
+}
+
+// ShowContext shows the content of a file with given file path and git reference in the cache directory, fetching the
+// content from the remote repository if the repository is a partial clone.
+func (a Git) ShowContext(ctx context.Context, r *git.Repository, resource *retriever.Resource) ([]byte, error) {
+	_, c, err := a.show(ctx, r, resource)
+	return c, err
+}
+
+// show shows the content of the file of the resource, returning the reference it was resolved to.
+func (a Git) show(ctx context.Context, r *git.Repository, resource *retriever.Resource) (*retriever.Reference, []byte, error) {
+	ref, err := a.Resolve(r, resource.Ref)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	commit, err := r.CommitObject(plumbing.NewHash(resource.Ref.Hash().String()))
+	commit, err := r.CommitObject(plumbing.NewHash(ref.Hash().String()))
 	if err != nil {
 		if errors.Is(err, plumbing.ErrObjectNotFound) {
-			return nil, fmt.Errorf("object of commit %s not found", resource.Ref.Hash())
+			return nil, nil, fmt.Errorf("object of commit %s not found", ref.Hash())
 		}
-		return nil, err
+		return nil, nil, err
+	}
+
+	if err := a.showPartial(ctx, r, resource.Repo, commit, resource.Filepath); err != nil {
+		return nil, nil, err
 	}
 
 	f, err := commit.File(resource.Filepath)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	contents, err := f.Contents()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return []byte(contents), nil
+	return ref, []byte(contents), nil
 }
 
 type checkoutOpts struct {
@@ -346,7 +383,7 @@ func (o checkoutOpts) String() string {
 // Checkout the repository at the reference of the given retriever.
 func (a Git) checkout(r *git.Repository, resource *retriever.Resource, opts checkoutOpts) error {
 	log.Debugf("checking out repository to resource: %v with opts: %v", resource, opts)
-	err := a.ResolveReference(r, resource)
+	ref, err := a.Resolve(r, resource.Ref)
 	if err != nil {
 		return err
 	}
@@ -357,27 +394,42 @@ func (a Git) checkout(r *git.Repository, resource *retriever.Resource, opts chec
 	}
 
 	return worktree.Checkout(&git.CheckoutOptions{
-		Hash:  plumbing.NewHash(resource.Ref.Hash().String()),
+		Hash:  plumbing.NewHash(ref.Hash().String()),
 		Force: opts.force,
 	})
 }
 
 // ResolveReference resolves a SymbolicReference to a HashReference.
-func (a Git) ResolveReference(r *git.Repository, resource *retriever.Resource) (err error) {
-	if resource.Ref == nil {
-		resource.Ref = retriever.HEADReference()
+//
+// Deprecated: ResolveReference replaces the reference of the resource, use Resolve instead.
+func (a Git) ResolveReference(r *git.Repository, resource *retriever.Resource) error {
+	ref, err := a.Resolve(r, resource.Ref)
+	if err != nil {
+		return err
 	}
+	resource.Ref = ref
+	return nil
+}
 
-	if resource.Ref.IsHash() {
-		return nil
+// Resolve resolves the reference (or HEAD, if nil) within the repository, returning a copy of the reference with the
+// hash of its commit (and, for HEAD, named after the branch it refers to). The reference itself isn't modified.
+func (a Git) Resolve(r *git.Repository, ref *retriever.Reference) (_ *retriever.Reference, err error) {
+	if ref == nil {
+		ref = retriever.HEADReference()
+	} else {
+		ref = ref.Clone()
+	}
+
+	if ref.IsHash() {
+		return ref, nil
 	}
 
 	var h *plumbing.Hash
-	rev := resource.Ref.Name()
+	rev := ref.Name()
 	if rev == "HEAD" {
-		ref, e := r.Reference("HEAD", false)
+		head, e := r.Reference("HEAD", false)
 		if e == nil {
-			resource.Ref.SetName(strings.TrimPrefix(ref.Target().String(), "refs/heads/"))
+			ref.SetName(strings.TrimPrefix(head.Target().String(), "refs/heads/"))
 		}
 		h, err = r.ResolveRevision(plumbing.Revision("refs/remotes/origin/HEAD"))
 	}
@@ -386,39 +438,52 @@ func (a Git) ResolveReference(r *git.Repository, resource *retriever.Resource) (
 		h, err = r.ResolveRevision(plumbing.Revision(rev))
 		if err != nil {
 			if errors.Is(err, plumbing.ErrReferenceNotFound) {
-				return fmt.Errorf("reference %s not found", rev)
+				return nil, fmt.Errorf("reference %s not found", rev)
 			}
-			return
+			return nil, err
 		}
 	}
 
 	hash, err := retriever.NewHash(h.String())
 	if err != nil {
-		return
+		return nil, err
+	}
+	if err := ref.SetHash(hash); err != nil {
+		return nil, err
 	}
-	err = resource.Ref.SetHash(hash)
-	return
+	return ref, nil
 }
 
 // TryResolveAsTag tries to resolve a SymbolicReference as a Tag Reference.
+//
+// Deprecated: TryResolveAsTag modifies the reference of the resource, use ResolveTag instead.
 func (a Git) TryResolveAsTag(r *git.Repository, resource *retriever.Resource) bool {
-	if resource.Ref == nil {
-		return false
+	ref, ok := a.ResolveTag(r, resource.Ref)
+	if ok {
+		resource.Ref = ref
 	}
+	return ok
+}
 
-	if resource.Ref.IsHash() {
-		return false
+// ResolveTag tries to resolve a SymbolicReference as a Tag Reference, returning a copy of the reference with the hash
+// of the tagged commit if it is a tag. The reference itself isn't modified.
+func (a Git) ResolveTag(r *git.Repository, ref *retriever.Reference) (*retriever.Reference, bool) {
+	if ref == nil {
+		return nil, false
 	}
 
-	var h *plumbing.Hash
-	if resource.Ref.IsHEAD() {
-		return false
+	if ref.IsHash() {
+		return nil, false
+	}
+
+	if ref.IsHEAD() {
+		return nil, false
 	}
 
-	rev := resource.Ref.Name()
+	rev := ref.Name()
 	if strings.HasPrefix(rev, "refs/") {
 		if !strings.HasPrefix(rev, "refs/tags/") {
-			return false
+			return nil, false
 		}
 
 		rev = rev[10:]
@@ -427,16 +492,18 @@ func (a Git) TryResolveAsTag(r *git.Repository, resource *retriever.Resource) bo
 	rev = "refs/tags/" + rev
 	h, err := r.ResolveRevision(plumbing.Revision(rev))
 	if err != nil {
-		return false
+		return nil, false
 	}
 
 	hash, err := retriever.NewHash(h.String())
 	if err != nil {
-		return false
+		return nil, false
 	}
-	err = resource.Ref.SetHash(hash)
-
-	return true
+	ref = ref.Clone()
+	if err := ref.SetHash(hash); err != nil {
+		return nil, false
+	}
+	return ref, true
 }
 
 // Session provides a mechanism to ensure that repeat requests to set the content of a repository to a given
@@ -457,6 +524,16 @@ type Session interface {
 
 	// Resolve the commit of the given reference within the repository.
 	Resolve(ctx context.Context, repo string, ref string, opts SessionResolveOpts) (*object.Commit, error)
+
+	// Entries returns every reference pinned within the session, ordered by repository and reference.
+	Entries() []SessionEntry
+
+	// Snapshot returns the references known to the session and the hashes they are pinned to.
+	Snapshot() SessionState
+
+	// Restore replaces the references known to the session with those of the given state, so that the session
+	// resolves references exactly as the session the state was taken from.
+	Restore(state SessionState) error
 }
 
 // SessionSetOpts provide configuration to the Session.Set method.
@@ -474,6 +551,9 @@ type SessionSetOpts struct {
 	// True to verify the repository is already at the requested reference (returning an error if it's not).
 	Verify bool
 
+	// The directories to check out (e.g. api), or all directories if empty.
+	Sparse []string
+
 	// Whether verbose (i.e. debug level) logs should be written when interacting with the session.
 	Verbose bool
 }
@@ -542,25 +622,39 @@ func (f SessionOptReset) String() string {
 }
 
 type sessionImpl struct {
-	once   once.Once
-	hashes map[string]string // The mapping of repo@ref to known hashes
-	g      *Git
+	once    once.Once
+	hashes  map[sessionKey]string // The mapping of repo@ref to known hashes
+	mutex   *sync.RWMutex         // Guards the hashes and writes to the journal
+	journal io.Writer             // Where newly known hashes are recorded, if the session is persistent
+	started *atomic.Bool          // Whether a reference has been set during the session
+	g       *Git
+}
+
+type sessionKey struct {
+	repo string
+	ref  string
 }
 
 func NewSession(g *Git) Session {
+	return newSession(g)
+}
+
+func newSession(g *Git) sessionImpl {
 	return sessionImpl{
-		once:   once.NewOnce(),
-		hashes: make(map[string]string),
-		g:      g}
+		once:    once.NewOnce(),
+		hashes:  make(map[sessionKey]string),
+		mutex:   &sync.RWMutex{},
+		started: &atomic.Bool{},
+		g:       g}
 }
 
 func (s sessionImpl) Set(ctx context.Context, repo string, ref string, opts SessionSetOpts) error {
-	_, err := s.set(ctx, repo, ref, opts.Fetch, opts.Reset, OptCheckoutTrue, opts.Depth, opts.Verify, opts.Verbose)
+	_, err := s.set(ctx, repo, ref, opts.Fetch, opts.Reset, OptCheckoutTrue, opts.Depth, opts.Verify, opts.Sparse, opts.Verbose)
 	return err
 }
 
 func (s sessionImpl) Resolve(ctx context.Context, repo string, ref string, opts SessionResolveOpts) (*object.Commit, error) {
-	result, err := s.set(ctx, repo, ref, opts.Fetch, SessionOptResetFalse, OptCheckoutFalse, opts.Depth, false, opts.Verbose)
+	result, err := s.set(ctx, repo, ref, opts.Fetch, SessionOptResetFalse, OptCheckoutFalse, opts.Depth, false, nil, opts.Verbose)
 	if err != nil {
 		return nil, err
 	}
@@ -569,19 +663,17 @@ func (s sessionImpl) Resolve(ctx context.Context, repo string, ref string, opts
 
 func (s sessionImpl) set(ctx context.Context, repo string, ref string,
 	optFetch SessionOptFetch, optReset SessionOptReset,
-	optCheckout OptCheckout, optDepth int, optVerify bool, optVerbose bool) (*SetResult, error) {
+	optCheckout OptCheckout, optDepth int, optVerify bool, optSparse []string, optVerbose bool) (*SetResult, error) {
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	default:
 		key := repo + "@" + ref
-		ch := s.once.Register(key)
+		s.once.Wait(key)
 		defer s.once.Unregister(key)
-		if ch != nil {
-			<-ch
-		}
 
 		// Maintain legacy behaviour.
+		origRef := ref
 		ref = strings.TrimPrefix(ref, "tags/")
 
 		if optVerbose {
@@ -591,10 +683,10 @@ func (s sessionImpl) set(ctx context.Context, repo string, ref string,
 		}
 
 		// Cache whether this is the first request for the session.
-		first := len(s.hashes) == 0
+		first := !s.started.Load()
 
 		// Cache the known session reference hash.
-		sessionRefHash, hasSessionRefHash := s.hashes[key]
+		sessionRefHash, hasSessionRefHash := s.lookup(repo, origRef)
 
 		// Use the session hash if known
 		if hasSessionRefHash && ref != sessionRefHash {
@@ -607,8 +699,8 @@ func (s sessionImpl) set(ctx context.Context, repo string, ref string,
 		switch optFetch {
 		case SessionOptFetchFirst:
 			fetch = OptFetchTrue
-			if hasSessionRefHash { // Don't fetch, it's not the first time the reference has been set within the session.
-				fetch = OptFetchFalse
+			if hasSessionRefHash { // Only fetch if unknown, the hash may have been restored from an earlier session.
+				fetch = OptFetchUnknown
 			}
 		case SessionOptFetchUnknown:
 			fetch = OptFetchUnknown
@@ -644,11 +736,15 @@ func (s sessionImpl) set(ctx context.Context, repo string, ref string,
 			Reset:    reset,
 			Depth:    optDepth,
 			Verify:   optVerify,
+			Sparse:   optSparse,
 			Checkout: optCheckout})
 		if err != nil {
 			return nil, err
 		}
-		s.hashes[key] = result.Commit.Hash.String()
+		if err := s.record(repo, origRef, result.Commit.Hash.String()); err != nil {
+			return nil, err
+		}
+		s.started.Store(true)
 		return result, nil
 	}
 }
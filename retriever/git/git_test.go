@@ -7,8 +7,11 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/go-git/go-git/v5/plumbing/object"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -1002,6 +1005,84 @@ func TestGitSession_Resolve_FetchFalse(t *testing.T) {
 	require.Error(t, err)
 }
 
+// Verify that concurrent requests to a session for many repositories and references are safe and stable (run with
+// -race).
+func TestGitSession_Concurrent(t *testing.T) {
+	g := NewWithCache(&AuthOptions{Local: true}, NewPlainFscache(t.TempDir()))
+	session := NewSession(g)
+
+	expected := map[SessionEntry]bool{}
+	for i := 0; i < 4; i++ {
+		remote, hashes := initLocalRepo(t, "one", "two")
+		require.NoError(t, execute(remote, "git", "tag", "v1", hashes[0]))
+		require.NoError(t, execute(remote, "git", "branch", pubRepoDevelopBranch, hashes[0]))
+		expected[SessionEntry{remote, pubRepoMainBranch, hashes[1]}] = true
+		expected[SessionEntry{remote, pubRepoDevelopBranch, hashes[0]}] = true
+		expected[SessionEntry{remote, "v1", hashes[0]}] = true
+		expected[SessionEntry{remote, hashes[1], hashes[1]}] = true
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4*len(expected))
+	for i := 0; i < 4; i++ {
+		for e := range expected {
+			wg.Add(1)
+			go func(i int, e SessionEntry) {
+				defer wg.Done()
+				var err error
+				var commit *object.Commit
+				if i%2 == 0 {
+					commit, err = session.Resolve(context.Background(), e.Repo, e.Ref, SessionResolveOpts{})
+				} else {
+					err = session.Set(context.Background(), e.Repo, e.Ref, SessionSetOpts{})
+				}
+				if err == nil && commit != nil && commit.Hash.String() != e.Hash {
+					err = fmt.Errorf("reference %v resolved to %v, expected %v", e.Ref, commit.Hash, e.Hash)
+				}
+				_ = session.Entries()
+				errs <- err
+			}(i, e)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	entries := session.Entries()
+	require.Len(t, entries, len(expected))
+	for _, e := range entries {
+		require.True(t, expected[e], e)
+	}
+}
+
+// Verify that the state of a session can be concurrently read and written (run with -race).
+func TestGitSession_Concurrent_State(t *testing.T) {
+	s := newSession(nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				ref := fmt.Sprintf("ref%d", (i+j)%10)
+				hash := fmt.Sprintf("%040d", (i+j)%10)
+				assert.NoError(t, s.record(pubRepo, ref, hash))
+				h, ok := s.lookup(pubRepo, ref)
+				assert.True(t, ok)
+				assert.Equal(t, hash, h)
+				_ = s.Entries()
+				_ = s.Snapshot()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	require.Len(t, s.Entries(), 10)
+}
+
 // execute the given command.
 func execute(dir string, name string, args ...string) error {
 	cmd := exec.Command(name, args...)
@@ -1017,3 +1098,34 @@ func execute(dir string, name string, args ...string) error {
 	}
 	return err
 }
+
+// initLocalRepo initialises a repository on the main branch of a temporary directory, committing each of the given
+// contents to README.md in turn. It returns the directory of the repository and the hashes of the commits.
+func initLocalRepo(t *testing.T, contents ...string) (string, []string) {
+	dir := t.TempDir()
+	require.NoError(t, execute(dir, "git", "init", "-q", "-b", pubRepoMainBranch))
+	hashes := make([]string, 0, len(contents))
+	for _, content := range contents {
+		hashes = append(hashes, commitLocalRepo(t, dir, "README.md", content))
+	}
+	return dir, hashes
+}
+
+// commitLocalRepo commits the content of the file to the repository in the directory, returning the commit hash.
+func commitLocalRepo(t *testing.T, dir, file, content string) string {
+	require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, file)), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, file), []byte(content), 0666))
+	require.NoError(t, execute(dir, "git", "add", "."))
+	require.NoError(t, execute(dir, "git", "-c", "user.name=Tester", "-c", "user.email=email@address.com",
+		"commit", "-q", "-m", content))
+	return revParse(t, dir, "HEAD")
+}
+
+// revParse returns the hash of the revision within the repository in the directory.
+func revParse(t *testing.T, dir, rev string) string {
+	cmd := exec.Command("git", "rev-parse", rev)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,336 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/anz-bank/golden-retriever/retriever"
+)
+
+// historyDepths are the depths of the progressively deeper fetches made when the history of a file extends beyond that
+// of a shallow repository, the last of which fetches the entire history.
+var historyDepths = shortHashDepths
+
+// HistoryOpts describes which history of a file to return.
+type HistoryOpts struct {
+	Limit int    // The maximum number of commits to return, or all if zero.
+	Since string // A reference (or hash) whose history (itself and its ancestors) is excluded, as git log since..from does.
+	Blame bool   // True to also blame each line of the file at the commit of the resource.
+}
+
+func (o HistoryOpts) String() string {
+	return fmt.Sprintf("{Limit:%v, Since:%v, Blame:%v}", o.Limit, o.Since, o.Blame)
+}
+
+// History is the history of a file.
+type History struct {
+	Commits []FileCommit // The commits that changed the file, most recent first.
+	Blame   []BlameLine  // The lines of the file with the commits that last changed them, if requested.
+}
+
+// FileCommit is a commit that changed a file.
+type FileCommit struct {
+	Hash    retriever.Hash
+	Author  string    // The name of the author.
+	Email   string    // The email address of the author.
+	Date    time.Time // The time the commit was authored.
+	Message string
+}
+
+// BlameLine is a line of a file with the commit that last changed it.
+type BlameLine struct {
+	Text   string
+	Hash   retriever.Hash
+	Author string    // The name of the author.
+	Email  string    // The email address of the author.
+	Date   time.Time // The time the commit was authored.
+}
+
+// History returns the commits that changed the file of the resource, ending at the commit of its reference.
+//
+// Shallow repositories are fetched progressively deeper until the history is found (or the entire history has been
+// fetched). Blaming the file requires its entire history.
+func (a Git) History(ctx context.Context, r *git.Repository, resource *retriever.Resource, opts HistoryOpts) (*History, error) {
+	log.Debugf("retrieving history of resource: %v with opts: %v", resource, opts)
+	ref, err := a.Resolve(r, resource.Ref)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := r.CommitObject(plumbing.NewHash(ref.Hash().String()))
+	if err != nil {
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			return nil, fmt.Errorf("object of commit %s not found", ref.Hash())
+		}
+		return nil, err
+	}
+
+	var since plumbing.Hash
+	if opts.Since != "" {
+		if since, err = a.resolveSince(ctx, r, resource.Repo, opts.Since); err != nil {
+			return nil, err
+		}
+	}
+
+	h := &History{}
+	commits, err := a.fileLog(ctx, r, resource.Repo, commit, resource.Filepath, since, opts.Limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range commits {
+		hash, err := retriever.NewHash(c.Hash.String())
+		if err != nil {
+			return nil, err
+		}
+		h.Commits = append(h.Commits, FileCommit{
+			Hash:    hash,
+			Author:  c.Author.Name,
+			Email:   c.Author.Email,
+			Date:    c.Author.When,
+			Message: c.Message,
+		})
+	}
+
+	if opts.Blame {
+		if h.Blame, err = a.blame(ctx, r, resource.Repo, commit, resource.Filepath); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// resolveSince resolves the reference at which to stop the history, within the repository if known.
+func (a Git) resolveSince(ctx context.Context, r *git.Repository, repo, since string) (plumbing.Hash, error) {
+	if h, err := r.ResolveRevision(plumbing.Revision(since)); err == nil {
+		return *h, nil
+	}
+	if h, err := retriever.NewHash(since); err == nil {
+		return plumbing.NewHash(h.String()), nil
+	}
+	rr, err := a.ResolveRemote(ctx, repo, since)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("error resolving reference: %v: %w", since, err)
+	}
+	return plumbing.NewHash(rr.Hash.String()), nil
+}
+
+// fileLog returns the commits that changed the file (up to the limit, if any), deepening the repository as required.
+func (a Git) fileLog(ctx context.Context, r *git.Repository, repo string, from *object.Commit, file string,
+	since plumbing.Hash, limit int) ([]*object.Commit, error) {
+	known, err := countCommits(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, depth := range historyDepths {
+		commits, complete, err := walkFileLog(r, from, file, since, limit, false)
+		if err != nil || complete {
+			return commits, err
+		}
+
+		log.Debugf("fetching repo: %v at depth: %v for history of file: %v", repo, depth, file)
+		if err := a.deepen(ctx, r, repo, from.Hash, depth); err != nil {
+			return nil, fmt.Errorf("error fetching history of file: %v: %w", file, err)
+		}
+		n, err := countCommits(r)
+		if err != nil {
+			return nil, err
+		}
+		if n == known {
+			break // The entire history has been fetched.
+		}
+		known = n
+	}
+	commits, _, err := walkFileLog(r, from, file, since, limit, true)
+	return commits, err
+}
+
+// walkFileLog walks the history from the commit (most recently committed first, as git log does), returning the commits
+// that changed the file and whether the history is complete. The history is incomplete if it reaches a commit whose
+// parents are unknown (i.e. the boundary of a shallow repository), unless the boundary is final.
+//
+// The history of since (if any) is excluded by walking it alongside that of the commit, marking its commits (and their
+// ancestors) as excluded, until only excluded commits remain to be walked.
+func walkFileLog(r *git.Repository, from *object.Commit, file string, since plumbing.Hash, limit int,
+	final bool) ([]*object.Commit, bool, error) {
+	var commits []*object.Commit
+	queue := []*object.Commit{from}
+	seen := map[plumbing.Hash]bool{from.Hash: true}
+	excluded := make(map[plumbing.Hash]bool)
+	if since == from.Hash {
+		return nil, true, nil
+	}
+	if !since.IsZero() {
+		s, err := r.CommitObject(since)
+		switch {
+		case errors.Is(err, plumbing.ErrObjectNotFound) && !final:
+			return nil, false, nil
+		case errors.Is(err, plumbing.ErrObjectNotFound):
+			excluded[since] = true // Unknown to the entire history, so only the commit itself can be excluded.
+		case err != nil:
+			return nil, false, err
+		default:
+			queue = append(queue, s)
+			excluded[since] = true
+		}
+		seen[since] = true
+	}
+	for slices.ContainsFunc(queue, func(c *object.Commit) bool { return !excluded[c.Hash] }) {
+		next := 0
+		for i, c := range queue {
+			if c.Committer.When.After(queue[next].Committer.When) {
+				next = i
+			}
+		}
+		c := queue[next]
+		queue = append(queue[:next], queue[next+1:]...)
+
+		parents, err := commitParents(r, c)
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			if !final {
+				return commits, false, nil
+			}
+			parents = nil // Treat the boundary of the history as its root, as git does.
+		} else if err != nil {
+			return nil, false, err
+		}
+
+		if excluded[c.Hash] {
+			for _, p := range parents {
+				excluded[p.Hash] = true
+				if !seen[p.Hash] {
+					seen[p.Hash] = true
+					queue = append(queue, p)
+				}
+			}
+			continue
+		}
+
+		changed, err := changesFile(c, parents, file)
+		if err != nil {
+			return nil, false, err
+		}
+		if changed {
+			commits = append(commits, c)
+			if limit > 0 && len(commits) == limit {
+				return commits, true, nil
+			}
+		}
+		for _, p := range parents {
+			if !seen[p.Hash] {
+				seen[p.Hash] = true
+				queue = append(queue, p)
+			}
+		}
+	}
+	return commits, true, nil
+}
+
+func commitParents(r *git.Repository, c *object.Commit) ([]*object.Commit, error) {
+	parents := make([]*object.Commit, 0, len(c.ParentHashes))
+	for _, h := range c.ParentHashes {
+		p, err := r.CommitObject(h)
+		if err != nil {
+			return nil, err
+		}
+		parents = append(parents, p)
+	}
+	return parents, nil
+}
+
+// changesFile reports whether the commit changed the file, i.e. the file differs from that of every parent.
+func changesFile(c *object.Commit, parents []*object.Commit, file string) (bool, error) {
+	h, err := fileHash(c, file)
+	if err != nil {
+		return false, err
+	}
+	if len(parents) == 0 {
+		return !h.IsZero(), nil
+	}
+	for _, p := range parents {
+		ph, err := fileHash(p, file)
+		if err != nil {
+			return false, err
+		}
+		if ph == h {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// fileHash returns the hash of the blob of the file within the commit, or zero if there's no such file.
+func fileHash(c *object.Commit, file string) (plumbing.Hash, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	entry, err := tree.FindEntry(file)
+	if errors.Is(err, object.ErrEntryNotFound) || errors.Is(err, object.ErrDirectoryNotFound) {
+		return plumbing.ZeroHash, nil
+	} else if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if !entry.Mode.IsFile() {
+		return plumbing.ZeroHash, nil
+	}
+	return entry.Hash, nil
+}
+
+// blame returns the lines of the file at the commit with the commits that last changed them, fetching the entire
+// history of the file first.
+func (a Git) blame(ctx context.Context, r *git.Repository, repo string, commit *object.Commit, file string) ([]BlameLine, error) {
+	commits, err := a.fileLog(ctx, r, repo, commit, file, plumbing.ZeroHash, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range commits {
+		if err := a.showPartial(ctx, r, repo, c, file); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := git.Blame(commit, file)
+	if err != nil {
+		return nil, fmt.Errorf("error blaming file: %v: %w", file, err)
+	}
+	lines := make([]BlameLine, 0, len(result.Lines))
+	for _, l := range result.Lines {
+		hash, err := retriever.NewHash(l.Hash.String())
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, BlameLine{Text: l.Text, Hash: hash, Author: l.AuthorName, Email: l.Author, Date: l.Date})
+	}
+	return lines, nil
+}
+
+// deepen fetches the history of the commit to the depth, without updating any references.
+func (a Git) deepen(ctx context.Context, r *git.Repository, repo string, hash plumbing.Hash, depth int) error {
+	return withAuth0(&a, repo, func(auth transport.AuthMethod, url string) error {
+		s, err := uploadPackSession(url, auth)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = s.Close() }()
+
+		ar, err := s.AdvertisedReferencesContext(ctx)
+		if err != nil {
+			return err
+		}
+		var filter packp.Filter
+		if IsPartial(r) && ar.Capabilities.Supports(capability.Filter) {
+			filter = partialCloneFilter
+		}
+		return fetchPack(ctx, s, ar, r.Storer, []plumbing.Hash{hash}, depth, filter)
+	})
+}
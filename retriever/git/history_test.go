@@ -0,0 +1,110 @@
+package git
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/anz-bank/golden-retriever/retriever"
+)
+
+func TestGitHistory(t *testing.T) {
+	dir, hashes := initLocalRepo(t, "v1\n", "v1\nv2\n")
+	commitLocalRepo(t, dir, "other.md", "other")
+	hashes = append(hashes, commitLocalRepo(t, dir, "README.md", "v1\nv2\nv3\n"))
+	commitLocalRepo(t, dir, "other.md", "other2")
+
+	g := NewWithCache(&AuthOptions{Local: true}, NewPlainFscache(t.TempDir()))
+	ctx := context.Background()
+	_, err := g.Retrieve(ctx, &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: retriever.HEADReference()})
+	require.NoError(t, err)
+	r, ok := g.cacher.Get(dir)
+	require.True(t, ok)
+
+	h, err := g.History(ctx, r, &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: retriever.HEADReference()}, HistoryOpts{})
+	require.NoError(t, err)
+	require.Equal(t, []string{hashes[2], hashes[1], hashes[0]}, fileCommitHashes(h.Commits))
+	require.Equal(t, "Tester", h.Commits[0].Author)
+	require.Equal(t, "email@address.com", h.Commits[0].Email)
+	require.Equal(t, "v1\nv2\nv3\n", h.Commits[0].Message)
+	require.False(t, h.Commits[0].Date.IsZero())
+	require.Nil(t, h.Blame)
+
+	h, err = g.History(ctx, r, &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: retriever.NewBranchReference("main")}, HistoryOpts{Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []string{hashes[2], hashes[1]}, fileCommitHashes(h.Commits))
+
+	h, err = g.History(ctx, r, &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: retriever.HEADReference()}, HistoryOpts{Since: hashes[1], Blame: true})
+	require.NoError(t, err)
+	require.Equal(t, []string{hashes[2]}, fileCommitHashes(h.Commits))
+	require.Len(t, h.Blame, 3)
+	for i, hash := range hashes {
+		require.Equal(t, hash, h.Blame[i].Hash.String())
+		require.Equal(t, "Tester", h.Blame[i].Author)
+	}
+	require.Equal(t, "v3", h.Blame[2].Text)
+
+	h, err = g.History(ctx, r, &retriever.Resource{Repo: dir, Filepath: "missing.md", Ref: retriever.HEADReference()}, HistoryOpts{})
+	require.NoError(t, err)
+	require.Empty(t, h.Commits)
+}
+
+func TestGitHistory_Partial(t *testing.T) {
+	dir, hashes := initLocalRepo(t, "v1\n", "v1\nv2\n")
+	require.NoError(t, execute(dir, "git", "config", "uploadpack.allowFilter", "true"))
+	require.NoError(t, execute(dir, "git", "config", "uploadpack.allowAnySHA1InWant", "true"))
+	g := NewWithOptions(&NewGitOptions{AuthOptions: &AuthOptions{Local: true}, Cacher: NewPlainFscache(t.TempDir()), PartialClone: true})
+	ctx := context.Background()
+	_, err := g.Retrieve(ctx, &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: retriever.HEADReference()})
+	require.NoError(t, err)
+	r, ok := g.cacher.Get(dir)
+	require.True(t, ok)
+
+	h, err := g.History(ctx, r, &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: retriever.HEADReference()}, HistoryOpts{Blame: true})
+	require.NoError(t, err)
+	require.Equal(t, []string{hashes[1], hashes[0]}, fileCommitHashes(h.Commits))
+	require.Len(t, h.Blame, 2)
+	require.Equal(t, hashes[0], h.Blame[0].Hash.String())
+	require.Equal(t, hashes[1], h.Blame[1].Hash.String())
+}
+
+// Verify that the history since a reference excludes all of its ancestors, including those reachable from merges.
+func TestGitHistory_SinceMerge(t *testing.T) {
+	dir, _ := initLocalRepo(t, "v1")
+	base := commitLocalRepo(t, dir, "spec.md", "v1")
+	require.NoError(t, execute(dir, "git", "checkout", "-q", "-b", "feature"))
+	feature := commitLocalRepo(t, dir, "spec.md", "v2")
+	require.NoError(t, execute(dir, "git", "checkout", "-q", pubRepoMainBranch))
+	since := commitLocalRepo(t, dir, "other.md", "other")
+	require.NoError(t, execute(dir, "git", "-c", "user.name=Tester", "-c", "user.email=email@address.com",
+		"merge", "-q", "--no-ff", "-m", "merge", "feature"))
+
+	g := NewWithCache(&AuthOptions{Local: true}, NewPlainFscache(t.TempDir()))
+	ctx := context.Background()
+	resource := &retriever.Resource{Repo: dir, Filepath: "spec.md", Ref: retriever.HEADReference()}
+	_, err := g.Retrieve(ctx, resource)
+	require.NoError(t, err)
+	r, ok := g.cacher.Get(dir)
+	require.True(t, ok)
+
+	h, err := g.History(ctx, r, resource, HistoryOpts{})
+	require.NoError(t, err)
+	require.Equal(t, []string{feature, base}, fileCommitHashes(h.Commits))
+
+	h, err = g.History(ctx, r, resource, HistoryOpts{Since: since})
+	require.NoError(t, err)
+	require.Equal(t, []string{feature}, fileCommitHashes(h.Commits))
+
+	h, err = g.History(ctx, r, resource, HistoryOpts{Since: "feature"})
+	require.NoError(t, err)
+	require.Empty(t, h.Commits)
+}
+
+func fileCommitHashes(commits []FileCommit) []string {
+	hashes := make([]string, 0, len(commits))
+	for _, c := range commits {
+		hashes = append(hashes, c.Hash.String())
+	}
+	return hashes
+}
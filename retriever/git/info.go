@@ -0,0 +1,98 @@
+package git
+
+import (
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+
+	"github.com/anz-bank/golden-retriever/retriever"
+)
+
+var _ retriever.InfoRetriever = Git{}
+
+// served is the remote repository (and authentication method) that fetched a repository.
+type served struct {
+	auth string
+	url  string
+}
+
+// setServed records that the repository was fetched from the url using the authentication method.
+func (a Git) setServed(repo string, meth Authenticator, url string) {
+	if a.served != nil {
+		a.served.Store(repo, served{auth: meth.Name(), url: url})
+	}
+}
+
+// servedBy returns the url of the remote repository and the name of the authentication method that last fetched the
+// repository, or the url of the origin of the repository (and no authentication method) if it wasn't fetched.
+func (a Git) servedBy(r *git.Repository, repo string) (string, string) {
+	if a.served != nil {
+		if s, ok := a.served.Load(repo); ok {
+			return s.(served).url, s.(served).auth
+		}
+	}
+	if r != nil {
+		if remote, err := r.Remote(git.DefaultRemoteName); err == nil && len(remote.Config().URLs) > 0 {
+			return remote.Config().URLs[0], ""
+		}
+	}
+	return repo, ""
+}
+
+// result returns the result of retrieving the content of the resource (whose reference has been resolved) from the
+// repository.
+func (a Git) result(r *git.Repository, resource *retriever.Resource, content []byte) (*retriever.Result, error) {
+	result := retriever.NewResult(resource, content)
+	result.RefType = refTypeOf(resource.Ref, func(tag string) bool {
+		_, err := r.Reference(plumbing.NewTagReferenceName(tag), false)
+		return err == nil
+	})
+
+	commit, err := r.CommitObject(plumbing.NewHash(resource.Ref.Hash().String()))
+	if err != nil {
+		return nil, err
+	}
+	result.Author = commit.Author.Name
+	result.Email = commit.Author.Email
+	result.Time = commit.Author.When
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	entry, err := tree.FindEntry(resource.Filepath)
+	if err != nil {
+		return nil, err
+	}
+	if result.Blob, err = retriever.NewHash(entry.Hash.String()); err != nil {
+		return nil, err
+	}
+	if result.Mode, err = entry.Mode.ToOSFileMode(); err != nil {
+		return nil, err
+	}
+
+	result.Remote, result.Auth = a.servedBy(r, resource.Repo)
+	return result, nil
+}
+
+// refTypeOf returns the type of the resolved reference, distinguishing symbolic references by whether they are tags.
+func refTypeOf(ref *retriever.Reference, isTag func(tag string) bool) retriever.ReferenceType {
+	switch {
+	case ref.Name() == "":
+		return retriever.ReferenceTypeHash
+	case ref.Type() != retriever.ReferenceTypeSymbolic:
+		return ref.Type()
+	}
+	name := ref.Name()
+	switch {
+	case strings.HasPrefix(name, "refs/tags/"):
+		return retriever.ReferenceTypeTag
+	case strings.HasPrefix(name, "refs/"):
+		return retriever.ReferenceTypeBranch
+	case isTag(strings.TrimPrefix(name, "tags/")):
+		return retriever.ReferenceTypeTag
+	default:
+		return retriever.ReferenceTypeBranch
+	}
+}
@@ -0,0 +1,87 @@
+package git
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/anz-bank/golden-retriever/retriever"
+)
+
+func TestGitRetrieveWithInfo(t *testing.T) {
+	dir, hashes := initLocalRepo(t, "v1", "v2")
+	require.NoError(t, execute(dir, "git", "tag", "v1.0.0", hashes[0]))
+	blob := revParse(t, dir, "main:README.md")
+
+	g := NewWithCache(&AuthOptions{Local: true}, NewPlainFscache(t.TempDir()))
+	ctx := context.Background()
+
+	result, err := g.RetrieveWithInfo(ctx, &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: retriever.HEADReference()})
+	require.NoError(t, err)
+	require.Equal(t, "v2", string(result.Content))
+	require.Equal(t, hashes[1], result.Hash.String())
+	require.Equal(t, "main", result.Ref)
+	require.Equal(t, retriever.ReferenceTypeBranch, result.RefType)
+	require.Equal(t, blob, result.Blob.String())
+	require.Equal(t, os.FileMode(0644), result.Mode)
+	require.Equal(t, int64(2), result.Size)
+	require.Equal(t, "Tester", result.Author)
+	require.Equal(t, "email@address.com", result.Email)
+	require.False(t, result.Time.IsZero())
+	require.Equal(t, dir, result.Remote)
+	require.Equal(t, Local{}.Name(), result.Auth)
+
+	// Tags are known once cloned, so aren't fetched again.
+	result, err = g.RetrieveWithInfo(ctx, &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: retriever.NewSymbolicReference("v1.0.0")})
+	require.NoError(t, err)
+	require.Equal(t, "v1", string(result.Content))
+	require.Equal(t, hashes[0], result.Hash.String())
+	require.Equal(t, "v1.0.0", result.Ref)
+	require.Equal(t, retriever.ReferenceTypeTag, result.RefType)
+	require.Equal(t, dir, result.Remote)
+	require.Empty(t, result.Auth)
+
+	hash, err := retriever.NewHash(hashes[0])
+	require.NoError(t, err)
+	ref, err := retriever.NewHashReference(hash)
+	require.NoError(t, err)
+	result, err = g.RetrieveWithInfo(ctx, &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: ref})
+	require.NoError(t, err)
+	require.Equal(t, hashes[0], result.Hash.String())
+	require.Empty(t, result.Ref)
+	require.Equal(t, retriever.ReferenceTypeHash, result.RefType)
+}
+
+func TestGitRetrieveUnmodified(t *testing.T) {
+	dir, hashes := initLocalRepo(t, "v1", "v2")
+	require.NoError(t, execute(dir, "git", "tag", "v1.0.0", hashes[0]))
+
+	g := NewWithCache(&AuthOptions{Local: true}, NewPlainFscache(t.TempDir()))
+	ctx := context.Background()
+
+	head := &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: retriever.HEADReference()}
+	content, err := g.Retrieve(ctx, head)
+	require.NoError(t, err)
+	require.Equal(t, "v2", string(content))
+	require.Equal(t, retriever.HEADReference(), head.Ref)
+
+	tag := &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: retriever.NewSymbolicReference("v1.0.0")}
+	result, err := g.RetrieveWithInfo(ctx, tag)
+	require.NoError(t, err)
+	require.Equal(t, hashes[0], result.Hash.String())
+	require.Equal(t, retriever.NewSymbolicReference("v1.0.0"), tag.Ref)
+
+	r, ok := g.cacher.Get(dir)
+	require.True(t, ok)
+	ref, err := g.Resolve(r, tag.Ref)
+	require.NoError(t, err)
+	require.Equal(t, hashes[0], ref.Hash().String())
+	require.True(t, tag.Ref.Hash().IsZero())
+
+	ref, ok = g.ResolveTag(r, tag.Ref)
+	require.True(t, ok)
+	require.Equal(t, hashes[0], ref.Hash().String())
+	require.True(t, tag.Ref.Hash().IsZero())
+}
@@ -0,0 +1,216 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/mod/semver"
+
+	"github.com/anz-bank/golden-retriever/retriever"
+)
+
+// Branch is a branch of a remote repository.
+type Branch struct {
+	Name string         // The short name of the branch, e.g. main.
+	Hash retriever.Hash // The hash of the commit at the head of the branch.
+}
+
+// Tag is a tag of a remote repository.
+type Tag struct {
+	Name      string            // The short name of the tag, e.g. v1.4.0.
+	Hash      retriever.Hash    // The hash of the tagged commit (peeled, for annotated tags).
+	Annotated bool              // Whether the tag is an annotated tag.
+	Object    retriever.Hash    // The hash of the annotated tag object, or zero for lightweight tags.
+	Message   string            // The message of the annotated tag, if requested.
+	Tagger    *object.Signature // The tagger of the annotated tag, if requested.
+}
+
+// RemoteRefs are the branches and tags of a remote repository.
+type RemoteRefs struct {
+	Branches []Branch
+	Tags     []Tag
+}
+
+// ListRefsFilter describes which references of a remote repository to list, and how.
+type ListRefsFilter struct {
+	Kind        OptRefKind // The kinds of references to list.
+	Pattern     string     // A glob (e.g. v1.*) matched against the short names of references, or all if empty.
+	Annotations bool       // True to retrieve the message and tagger of annotated tags.
+	Descending  bool       // True to sort references in descending order.
+}
+
+func (f ListRefsFilter) String() string {
+	return fmt.Sprintf("{Kind:%v, Pattern:%v, Annotations:%v, Descending:%v}",
+		f.Kind, f.Pattern, f.Annotations, f.Descending)
+}
+
+// OptRefKind describes the kinds of references to list.
+type OptRefKind int
+
+const (
+	OptRefKindAll      OptRefKind = iota // List branches and tags.
+	OptRefKindBranches                   // List branches only.
+	OptRefKindTags                       // List tags only.
+)
+
+func (k OptRefKind) String() string {
+	switch k {
+	case OptRefKindAll:
+		return "all"
+	case OptRefKindBranches:
+		return "branches"
+	case OptRefKindTags:
+		return "tags"
+	default:
+		return "-"
+	}
+}
+
+// ListRefs lists the branches and tags of the remote repository without cloning it.
+//
+// Branches are sorted by name. Tags are sorted by semantic version (with or without a v prefix, e.g. v1.4.0 or 1.4.0),
+// following any tags that aren't semantic versions (which are sorted by name).
+//
+// Retrieving the message and tagger of annotated tags requires fetching the tag objects (but not the content of the
+// tagged commits where the remote repository supports filtering objects).
+func (a Git) ListRefs(ctx context.Context, repo string, filter ListRefsFilter) (*RemoteRefs, error) {
+	log.Debugf("listing references of repo: %v with filter: %v", repo, filter)
+	if filter.Pattern != "" {
+		if _, err := path.Match(filter.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern: %v: %w", filter.Pattern, err)
+		}
+	}
+	ad, err := a.lsRemote(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := &RemoteRefs{}
+	for name := range ad.hashes {
+		n := plumbing.ReferenceName(name)
+		short := n.Short()
+		if filter.Pattern != "" {
+			if ok, _ := path.Match(filter.Pattern, short); !ok {
+				continue
+			}
+		}
+		switch {
+		case n.IsBranch() && filter.Kind != OptRefKindTags:
+			rr, ok := ad.ref(name)
+			if !ok {
+				continue
+			}
+			refs.Branches = append(refs.Branches, Branch{Name: short, Hash: rr.Hash})
+		case n.IsTag() && filter.Kind != OptRefKindBranches:
+			rr, ok := ad.ref(name)
+			if !ok {
+				continue
+			}
+			refs.Tags = append(refs.Tags, Tag{Name: short, Hash: rr.Hash, Annotated: !rr.Tag.IsZero(), Object: rr.Tag})
+		}
+	}
+
+	if filter.Annotations {
+		if err := a.annotateTags(ctx, repo, refs.Tags); err != nil {
+			return nil, err
+		}
+	}
+
+	slices.SortFunc(refs.Branches, func(x, y Branch) int { return strings.Compare(x.Name, y.Name) })
+	slices.SortFunc(refs.Tags, func(x, y Tag) int { return compareTagNames(x.Name, y.Name) })
+	if filter.Descending {
+		slices.Reverse(refs.Branches)
+		slices.Reverse(refs.Tags)
+	}
+	return refs, nil
+}
+
+// compareTagNames compares tag names by semantic version, ordering tags that aren't semantic versions first by name.
+func compareTagNames(x, y string) int {
+	vx, vy := semverOf(x), semverOf(y)
+	switch {
+	case vx == "" && vy == "":
+		return strings.Compare(x, y)
+	case vx == "":
+		return -1
+	case vy == "":
+		return 1
+	}
+	if c := semver.Compare(vx, vy); c != 0 {
+		return c
+	}
+	return strings.Compare(x, y)
+}
+
+// semverOf returns the semantic version of the tag name (e.g. v1.4.0 for 1.4.0), or empty if it isn't one.
+func semverOf(name string) string {
+	v := name
+	if !strings.HasPrefix(v, "v") {
+		v = "v" + v
+	}
+	if !semver.IsValid(v) {
+		return ""
+	}
+	return v
+}
+
+// annotateTags sets the message and tagger of the annotated tags, fetching the tag objects from the remote repository.
+func (a Git) annotateTags(ctx context.Context, repo string, tags []Tag) error {
+	var wants []plumbing.Hash
+	for _, t := range tags {
+		if t.Annotated {
+			if t.Object.IsSHA256() {
+				return fmt.Errorf("annotations of tags of SHA-256 repositories aren't supported")
+			}
+			wants = append(wants, plumbing.NewHash(t.Object.String()))
+		}
+	}
+	if len(wants) == 0 {
+		return nil
+	}
+
+	st := memory.NewStorage()
+	err := withAuth0(&a, repo, func(auth transport.AuthMethod, url string) error {
+		s, err := uploadPackSession(url, auth)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = s.Close() }()
+
+		ar, err := s.AdvertisedReferencesContext(ctx)
+		if err != nil {
+			return err
+		}
+		var filter packp.Filter
+		if ar.Capabilities.Supports(capability.Filter) {
+			filter = packp.FilterTreeDepth(0) // Only the tag and commit objects are required.
+		}
+		return fetchPack(ctx, s, ar, st, wants, 1, filter)
+	})
+	if err != nil {
+		return fmt.Errorf("error fetching tags of repository: %v: %w", repo, err)
+	}
+
+	for i, t := range tags {
+		if !t.Annotated {
+			continue
+		}
+		tag, err := object.GetTag(st, plumbing.NewHash(t.Object.String()))
+		if err != nil {
+			return fmt.Errorf("error reading tag: %v: %w", t.Name, err)
+		}
+		tags[i].Message = tag.Message
+		tags[i].Tagger = &tag.Tagger
+	}
+	return nil
+}
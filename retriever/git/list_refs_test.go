@@ -0,0 +1,72 @@
+package git
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitListRefs(t *testing.T) {
+	dir, hashes := initLocalRepo(t, "v1", "v2", "v3")
+	tag := func(args ...string) {
+		require.NoError(t, execute(dir, "git", append([]string{"-c", "user.name=Tester", "-c", "user.email=email@address.com", "tag"}, args...)...))
+	}
+	tag("v1.2.0", hashes[0])
+	tag("-a", "-m", "release 1.10.0", "v1.10.0", hashes[2])
+	tag("1.9.0", hashes[1])
+	tag("v2.0.0-rc.1", hashes[2])
+	tag("latest", hashes[2])
+	require.NoError(t, execute(dir, "git", "branch", "release/1.x", hashes[1]))
+	require.NoError(t, execute(dir, "git", "branch", "develop", hashes[0]))
+	require.NoError(t, execute(dir, "git", "config", "uploadpack.allowFilter", "true"))
+
+	g := NewWithCache(&AuthOptions{Local: true}, NewPlainFscache(t.TempDir()))
+	ctx := context.Background()
+
+	refs, err := g.ListRefs(ctx, dir, ListRefsFilter{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"develop", "main", "release/1.x"}, branchNames(refs.Branches))
+	require.Equal(t, []string{"latest", "v1.2.0", "1.9.0", "v1.10.0", "v2.0.0-rc.1"}, tagNames(refs.Tags))
+	require.Equal(t, hashes[1], refs.Branches[2].Hash.String())
+
+	annotated := refs.Tags[3]
+	require.True(t, annotated.Annotated)
+	require.Equal(t, hashes[2], annotated.Hash.String())
+	require.Equal(t, revParse(t, dir, "v1.10.0"), annotated.Object.String())
+	require.Empty(t, annotated.Message)
+	require.False(t, refs.Tags[1].Annotated)
+	require.True(t, refs.Tags[1].Object.IsZero())
+
+	refs, err = g.ListRefs(ctx, dir, ListRefsFilter{Kind: OptRefKindTags, Pattern: "v1.*", Annotations: true, Descending: true})
+	require.NoError(t, err)
+	require.Empty(t, refs.Branches)
+	require.Equal(t, []string{"v1.10.0", "v1.2.0"}, tagNames(refs.Tags))
+	require.Equal(t, "release 1.10.0\n", refs.Tags[0].Message)
+	require.Equal(t, "Tester", refs.Tags[0].Tagger.Name)
+	require.Nil(t, refs.Tags[1].Tagger)
+
+	refs, err = g.ListRefs(ctx, dir, ListRefsFilter{Kind: OptRefKindBranches, Pattern: "release/*"})
+	require.NoError(t, err)
+	require.Empty(t, refs.Tags)
+	require.Equal(t, []string{"release/1.x"}, branchNames(refs.Branches))
+
+	_, err = g.ListRefs(ctx, dir, ListRefsFilter{Pattern: "["})
+	require.ErrorContains(t, err, "invalid pattern")
+}
+
+func branchNames(branches []Branch) []string {
+	names := make([]string, 0, len(branches))
+	for _, b := range branches {
+		names = append(names, b.Name)
+	}
+	return names
+}
+
+func tagNames(tags []Tag) []string {
+	names := make([]string, 0, len(tags))
+	for _, t := range tags {
+		names = append(names, t.Name)
+	}
+	return names
+}
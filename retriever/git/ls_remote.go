@@ -0,0 +1,164 @@
+package git
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/anz-bank/golden-retriever/retriever"
+)
+
+// Resolver resolves references of remote repositories without cloning or fetching them.
+type Resolver interface {
+	// ResolveRemote resolves the reference (e.g. main, v1.4.0, refs/heads/main or HEAD) of the remote repository.
+	ResolveRemote(ctx context.Context, repo, ref string) (*RemoteRef, error)
+}
+
+var _ Resolver = Git{}
+
+// RemoteRef is a reference of a remote repository resolved to the commit it refers to.
+type RemoteRef struct {
+	Name   string         // The full name of the reference, e.g. refs/heads/main, refs/tags/v1.4.0 or HEAD.
+	Target string         // The reference HEAD refers to (e.g. refs/heads/main), if advertised by the remote.
+	Hash   retriever.Hash // The hash of the commit, with annotated tags peeled.
+	Tag    retriever.Hash // The hash of the annotated tag object, or zero if the reference isn't an annotated tag.
+}
+
+// ResolveRemote resolves the reference of the remote repository to the hash of its commit using the references
+// advertised by the remote repository (as git ls-remote does), so no local repository is required.
+//
+// Symbolic references are resolved in the same order as git (i.e. tags before branches), and full hashes are returned
+// as they are.
+func (a Git) ResolveRemote(ctx context.Context, repo, ref string) (*RemoteRef, error) {
+	if h, err := retriever.NewHash(ref); err == nil {
+		return &RemoteRef{Hash: h}, nil
+	}
+	ad, err := a.lsRemote(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	return ad.resolve(ref)
+}
+
+// advertisement is the set of references advertised by a remote repository.
+type advertisement struct {
+	hashes map[string]string // The hashes of the references, keyed by their full name.
+	peeled map[string]string // The hashes of the commits of annotated tags, keyed by their full name.
+	head   string            // The target of HEAD, if advertised.
+}
+
+func (ad *advertisement) resolve(ref string) (*RemoteRef, error) {
+	if ref == "" {
+		ref = retriever.HEAD
+	}
+	names := []string{ref}
+	if ref != retriever.HEAD && !strings.HasPrefix(ref, "refs/") {
+		names = names[:0]
+		for _, rule := range plumbing.RefRevParseRules {
+			names = append(names, fmt.Sprintf(rule, ref))
+		}
+	}
+	for _, n := range names {
+		if rr, ok := ad.ref(n); ok {
+			return rr, nil
+		}
+	}
+	return nil, fmt.Errorf("reference %s not found: %w", ref, plumbing.ErrReferenceNotFound)
+}
+
+// ref returns the reference with the full name, if advertised.
+func (ad *advertisement) ref(name string) (*RemoteRef, bool) {
+	h, ok := ad.hashes[name]
+	if !ok {
+		return nil, false
+	}
+	rr := &RemoteRef{Name: name}
+	if name == retriever.HEAD {
+		rr.Target = ad.head
+	}
+	var err error
+	if p, peeled := ad.peeled[name]; peeled {
+		if rr.Tag, err = retriever.NewHash(h); err != nil {
+			return nil, false
+		}
+		h = p
+	}
+	if rr.Hash, err = retriever.NewHash(h); err != nil {
+		return nil, false
+	}
+	return rr, true
+}
+
+// lsRemote returns the references advertised by the remote repository.
+func (a Git) lsRemote(ctx context.Context, repo string) (*advertisement, error) {
+	log.Debugf("listing references of remote repo: %v", repo)
+	ad, err := withAuth1(&a, repo, func(auth transport.AuthMethod, url string) (*advertisement, error) {
+		s, err := uploadPackSession(url, auth)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = s.Close() }()
+
+		ar, err := s.AdvertisedReferencesContext(ctx)
+		if isSHA256AdvertisedErr(err) {
+			return lsRemoteCLI(ctx, auth, url)
+		} else if err != nil {
+			return nil, err
+		}
+
+		ad := &advertisement{hashes: map[string]string{}, peeled: map[string]string{}}
+		for name, h := range ar.References {
+			ad.hashes[name] = h.String()
+		}
+		for name, h := range ar.Peeled {
+			ad.peeled[name] = h.String()
+		}
+		if ar.Head != nil {
+			ad.hashes[retriever.HEAD] = ar.Head.String()
+		}
+		for _, symref := range ar.Capabilities.Get(capability.SymRef) {
+			if name, target, ok := strings.Cut(symref, ":"); ok && name == retriever.HEAD {
+				ad.head = target
+			}
+		}
+		return ad, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing references of remote repository: %v: %w", repo, err)
+	}
+	return ad, nil
+}
+
+// lsRemoteCLI returns the references advertised by the remote repository using the git cli (for repositories using
+// the SHA-256 object format).
+func lsRemoteCLI(ctx context.Context, auth transport.AuthMethod, url string) (*advertisement, error) {
+	out, err := gitCLIEnv(ctx, "", cliAuthEnv(auth), "ls-remote", "--symref", url)
+	if err != nil {
+		return nil, err
+	}
+	ad := &advertisement{hashes: map[string]string{}, peeled: map[string]string{}}
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		h, name, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(h, "ref: "):
+			if name == retriever.HEAD {
+				ad.head = strings.TrimPrefix(h, "ref: ")
+			}
+		case strings.HasSuffix(name, "^{}"):
+			ad.peeled[strings.TrimSuffix(name, "^{}")] = h
+		default:
+			ad.hashes[name] = h
+		}
+	}
+	return ad, scanner.Err()
+}
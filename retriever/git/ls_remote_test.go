@@ -0,0 +1,72 @@
+package git
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitResolveRemote(t *testing.T) {
+	dir, hashes := initLocalRepo(t, "v1", "v2")
+	require.NoError(t, execute(dir, "git", "tag", "v1", hashes[0]))
+	require.NoError(t, execute(dir, "git", "-c", "user.name=Tester", "-c", "user.email=email@address.com",
+		"tag", "-a", "-m", "release", "v2", hashes[1]))
+	require.NoError(t, execute(dir, "git", "branch", "develop", hashes[0]))
+	tagHash := revParse(t, dir, "v2")
+
+	cacher := NewPlainFscache(t.TempDir())
+	g := NewWithCache(&AuthOptions{Local: true}, cacher)
+
+	tests := []struct {
+		ref    string
+		name   string
+		hash   string
+		tag    string
+		target string
+	}{
+		{ref: "HEAD", name: "HEAD", hash: hashes[1], target: "refs/heads/main"},
+		{ref: "", name: "HEAD", hash: hashes[1], target: "refs/heads/main"},
+		{ref: "main", name: "refs/heads/main", hash: hashes[1]},
+		{ref: "develop", name: "refs/heads/develop", hash: hashes[0]},
+		{ref: "refs/heads/develop", name: "refs/heads/develop", hash: hashes[0]},
+		{ref: "v1", name: "refs/tags/v1", hash: hashes[0]},
+		{ref: "tags/v2", name: "refs/tags/v2", hash: hashes[1], tag: tagHash},
+		{ref: hashes[0], hash: hashes[0]},
+	}
+	for _, test := range tests {
+		t.Run(test.ref, func(t *testing.T) {
+			rr, err := g.ResolveRemote(context.Background(), dir, test.ref)
+			require.NoError(t, err)
+			require.Equal(t, test.name, rr.Name)
+			require.Equal(t, test.hash, rr.Hash.String())
+			require.Equal(t, test.tag, rr.Tag.String())
+			require.Equal(t, test.target, rr.Target)
+		})
+	}
+
+	_, err := g.ResolveRemote(context.Background(), dir, "missing")
+	require.ErrorIs(t, err, plumbing.ErrReferenceNotFound)
+
+	// No local repository is required.
+	_, ok := cacher.Get(dir)
+	require.False(t, ok)
+}
+
+func TestGitResolveRemote_SHA256(t *testing.T) {
+	dir, hashes := initSHA256Repo(t, "v1")
+	require.NoError(t, execute(dir, "git", "-c", "user.name=Tester", "-c", "user.email=email@address.com",
+		"tag", "-a", "-m", "release", "v1", hashes[0]))
+	g := NewWithCache(&AuthOptions{Local: true}, NewPlainFscache(t.TempDir()))
+
+	rr, err := g.ResolveRemote(context.Background(), dir, "v1")
+	require.NoError(t, err)
+	require.Equal(t, hashes[0], rr.Hash.String())
+	require.Equal(t, revParse(t, dir, "v1"), rr.Tag.String())
+
+	rr, err = g.ResolveRemote(context.Background(), dir, "HEAD")
+	require.NoError(t, err)
+	require.Equal(t, hashes[0], rr.Hash.String())
+	require.Equal(t, "refs/heads/main", rr.Target)
+}
@@ -0,0 +1,75 @@
+package git
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-git/go-git/v5"
+
+	"github.com/anz-bank/golden-retriever/retriever"
+)
+
+// In offline mode (see NewGitOptions.Offline), remote repositories are never contacted: nothing is cloned or fetched,
+// references aren't listed or resolved via the API of their host, and blobs omitted from partial clones aren't fetched.
+// Resources are retrieved from the cache only, with symbolic references (branches, tags and HEAD) resolved against the
+// references of the cached repository (or pinned to hashes beforehand, e.g. by a pinner).
+
+// ErrOffline is returned (wrapped) in offline mode when retrieving content that isn't available locally.
+var ErrOffline = retriever.ErrOffline
+
+// Offline reports whether remote repositories are never contacted.
+func (a Git) Offline() bool {
+	return a.offline
+}
+
+// errOffline returns ErrOffline for the operation on the repository, which requires contacting it.
+func errOffline(op, repo string) error {
+	return fmt.Errorf("%w: %s repository: %v requires network access", ErrOffline, op, repo)
+}
+
+// fetchOffline returns the cached repository of the resource (a copy of that of the caller), resolving its reference
+// (including short hashes) within the repository, or ErrOffline if the repository or the commit of the reference isn't
+// cached.
+func (a Git) fetchOffline(ctx context.Context, resource *retriever.Resource) (*git.Repository, error) {
+	r, ok := a.cacher.Get(resource.Repo)
+	if !ok {
+		return nil, fmt.Errorf("%w: repository: %v isn't cached", ErrOffline, resource.Repo)
+	}
+	if resource.Ref.IsShortHash() && !resource.Ref.IsHash() && a.isRefName(ctx, r, resource.Repo, resource.Ref.Name()) {
+		resource.Ref = retriever.NewSymbolicReference(resource.Ref.Name())
+	}
+	if resource.Ref.IsShortHash() && !resource.Ref.IsHash() {
+		h, found, err := matchShortHash(r, resource.Ref.Name())
+		if err != nil {
+			return nil, fmt.Errorf("git resolve: %w", err)
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: short hash: %v isn't cached for repository: %v", ErrOffline, resource.Ref.Name(), resource.Repo)
+		}
+		hash, err := retriever.NewHash(h)
+		if err != nil {
+			return nil, err
+		}
+		if err := resource.Ref.SetHash(hash); err != nil {
+			return nil, err
+		}
+	}
+
+	// Resolve the reference (and check its commit is cached) without showing the file, so that files missing from the
+	// commit are reported as such.
+	ref, err := a.present(ctx, r, &retriever.Resource{Repo: resource.Repo, Ref: resource.Ref})
+	if err != nil {
+		return nil, fmt.Errorf("%w: reference: %v of repository: %v isn't cached: %v", ErrOffline, resource.Ref, resource.Repo, err)
+	}
+	resource.Ref = ref
+	return r, nil
+}
+
+// fetchOpt returns how to fetch content in place of the given option, which in offline mode is only if the reference
+// is unknown to the local repository (which then fails with ErrOffline).
+func (a Git) fetchOpt(fetch OptFetch) OptFetch {
+	if a.offline && fetch == OptFetchTrue {
+		return OptFetchUnknown
+	}
+	return fetch
+}
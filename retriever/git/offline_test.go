@@ -0,0 +1,73 @@
+package git
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/anz-bank/golden-retriever/retriever"
+)
+
+func TestGitOffline(t *testing.T) {
+	dir, hashes := initLocalRepo(t, "v1", "v2")
+	require.NoError(t, execute(dir, "git", "tag", "v1.0.0", hashes[0]))
+	cache := t.TempDir()
+	ctx := context.Background()
+
+	online := NewWithCache(&AuthOptions{Local: true}, NewPlainFscache(cache))
+	_, err := online.Retrieve(ctx, &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: retriever.NewBranchReference("main")})
+	require.NoError(t, err)
+
+	g := NewWithOptions(&NewGitOptions{AuthOptions: &AuthOptions{Local: true}, Cacher: NewPlainFscache(cache), Offline: true})
+	require.True(t, g.Offline())
+
+	// Branches aren't fetched, so are resolved as cached.
+	commitLocalRepo(t, dir, "README.md", "v3")
+	result, err := g.RetrieveWithInfo(ctx, &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: retriever.NewBranchReference("main")})
+	require.NoError(t, err)
+	require.Equal(t, "v2", string(result.Content))
+	require.Equal(t, hashes[1], result.Hash.String())
+	require.Equal(t, "main", result.Ref)
+
+	result, err = g.RetrieveWithInfo(ctx, &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: retriever.HEADReference()})
+	require.NoError(t, err)
+	require.Equal(t, hashes[1], result.Hash.String())
+
+	result, err = g.RetrieveWithInfo(ctx, &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: retriever.NewSymbolicReference("v1.0.0")})
+	require.NoError(t, err)
+	require.Equal(t, "v1", string(result.Content))
+
+	short, err := retriever.NewShortHashReference(hashes[1][:8])
+	require.NoError(t, err)
+	result, err = g.RetrieveWithInfo(ctx, &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: short})
+	require.NoError(t, err)
+	require.Equal(t, hashes[1], result.Hash.String())
+
+	// Files missing from cached commits aren't reported as offline.
+	_, err = g.Retrieve(ctx, &retriever.Resource{Repo: dir, Filepath: "missing.md", Ref: retriever.NewBranchReference("main")})
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrOffline)
+
+	for _, resource := range []*retriever.Resource{
+		{Repo: dir, Filepath: "README.md", Ref: retriever.NewBranchReference("develop")},
+		{Repo: dir, Filepath: "README.md", Ref: refOf(t, revParse(t, dir, "HEAD"))},
+		{Repo: t.TempDir(), Filepath: "README.md", Ref: retriever.HEADReference()},
+	} {
+		_, err = g.Retrieve(ctx, resource)
+		require.ErrorIs(t, err, ErrOffline, resource.String())
+	}
+	_, err = g.ResolveRemote(ctx, dir, "main")
+	require.ErrorIs(t, err, ErrOffline)
+	_, err = g.Set(ctx, t.TempDir(), "main", SetOpts{})
+	require.ErrorIs(t, err, ErrOffline)
+}
+
+// refOf returns the hash reference of the hash.
+func refOf(t *testing.T, h string) *retriever.Reference {
+	hash, err := retriever.NewHash(h)
+	require.NoError(t, err)
+	ref, err := retriever.NewHashReference(hash)
+	require.NoError(t, err)
+	return ref
+}
@@ -0,0 +1,314 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/sideband"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/storage"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/anz-bank/golden-retriever/retriever"
+)
+
+// partialCloneFilter is the object filter of partial clones, which omits every blob until it is shown.
+var partialCloneFilter = packp.FilterBlobNone()
+
+// IsPartial reports whether the repository is a partial clone, i.e. whether blobs missing from the repository are
+// expected to be fetched from the remote repository on demand.
+func IsPartial(r *git.Repository) bool {
+	cfg, err := r.Config()
+	if err != nil {
+		return false
+	}
+	return cfg.Raw.Section("remote").Subsection(git.DefaultRemoteName).Option("promisor") == "true"
+}
+
+// clonePartial initialises a repository and fetches the reference of the resource without any blobs.
+func (a Git) clonePartial(ctx context.Context, resource *retriever.Resource, depth int) (r *git.Repository, err error) {
+	repo := resource.Repo
+	if a.offline {
+		return nil, errOffline("cloning", repo)
+	}
+	if c, isPlain := a.cacher.(PlainFsCache); isPlain {
+		r, err = git.PlainInit(c.RepoDir(repo), false)
+	} else {
+		r, err = git.Init(a.cacher.NewStorer(repo), nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, a.FetchPartial(ctx, r, repo, resource.Ref, depth)
+}
+
+// FetchPartial fetches the commits and trees of the reference (to the given depth) from the remote repository,
+// leaving the blobs to be fetched on demand when a file is shown.
+//
+// If the remote repository doesn't support filtering objects, all objects are fetched and the repository is left as a
+// regular (i.e. complete) repository.
+func (a Git) FetchPartial(ctx context.Context, r *git.Repository, repo string, ref *retriever.Reference, depth int) error {
+	if ref == nil {
+		ref = retriever.HEADReference()
+	}
+	log.Debugf("partially fetching repo: %v at reference: %v with depth: %v", repo, ref, depth)
+	return withAuth0(&a, repo, func(auth transport.AuthMethod, url string) error {
+		s, err := uploadPackSession(url, auth)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = s.Close() }()
+
+		ar, err := s.AdvertisedReferencesContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		var want plumbing.Hash
+		var refs []*plumbing.Reference
+		if ref.IsHash() {
+			want = plumbing.NewHash(ref.Hash().String())
+		} else {
+			want, refs, err = partialRefs(ar, ref.Name())
+			if err != nil {
+				return err
+			}
+		}
+
+		var filter packp.Filter
+		filtered := ar.Capabilities.Supports(capability.Filter)
+		if filtered {
+			filter = partialCloneFilter
+		}
+		if _, err := r.Storer.EncodedObject(plumbing.AnyObject, want); err != nil {
+			if !filtered {
+				log.Debugf("remote repository: %v doesn't support filters, fetching all objects", repo)
+			}
+			if err := fetchPack(ctx, s, ar, r.Storer, []plumbing.Hash{want}, depth, filter); err != nil {
+				return err
+			}
+		}
+
+		for _, rf := range refs {
+			if err := r.Storer.SetReference(rf); err != nil {
+				return err
+			}
+		}
+		return setPromisor(r, url, filtered)
+	})
+}
+
+// partialRefs returns the hash of the named reference as advertised by the remote repository, along with the local
+// references it should be stored as (mirroring those of a regular clone or fetch).
+func partialRefs(ar *packp.AdvRefs, name string) (plumbing.Hash, []*plumbing.Reference, error) {
+	remote, err := ar.AllReferences()
+	if err != nil {
+		return plumbing.ZeroHash, nil, err
+	}
+
+	if name == "HEAD" {
+		head, err := remote.Reference(plumbing.HEAD)
+		if err != nil {
+			return plumbing.ZeroHash, nil, fmt.Errorf("reference %s not found", name)
+		}
+		resolved, err := storer.ResolveReference(remote, plumbing.HEAD)
+		if err != nil {
+			return plumbing.ZeroHash, nil, err
+		}
+		h := resolved.Hash()
+		refs := []*plumbing.Reference{
+			plumbing.NewHashReference(plumbing.NewRemoteHEADReferenceName(git.DefaultRemoteName), h),
+		}
+		if head.Type() == plumbing.SymbolicReference {
+			refs = append(refs,
+				head,
+				plumbing.NewHashReference(head.Target(), h),
+				plumbing.NewHashReference(plumbing.NewRemoteReferenceName(git.DefaultRemoteName, head.Target().Short()), h))
+		}
+		return h, refs, nil
+	}
+
+	for _, rule := range plumbing.RefRevParseRules {
+		n := plumbing.ReferenceName(fmt.Sprintf(rule, name))
+		rf, err := storer.ResolveReference(remote, n)
+		if err != nil {
+			continue
+		}
+		h := rf.Hash()
+		switch {
+		case n.IsBranch():
+			return h, []*plumbing.Reference{
+				plumbing.NewHashReference(n, h),
+				plumbing.NewHashReference(plumbing.NewRemoteReferenceName(git.DefaultRemoteName, n.Short()), h),
+			}, nil
+		default:
+			return h, []*plumbing.Reference{plumbing.NewHashReference(n, h)}, nil
+		}
+	}
+	return plumbing.ZeroHash, nil, fmt.Errorf("reference %s not found", name)
+}
+
+// setPromisor records the remote repository as the promisor of the omitted objects of the repository, in the same
+// manner as git, so that the git cli also fetches missing objects on demand.
+func setPromisor(r *git.Repository, url string, filtered bool) error {
+	if _, err := r.Remote(git.DefaultRemoteName); errors.Is(err, git.ErrRemoteNotFound) {
+		if _, err := r.CreateRemote(&config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{url}}); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	if !filtered || IsPartial(r) {
+		return nil
+	}
+	cfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+	cfg.Raw.Section("remote").Subsection(git.DefaultRemoteName).
+		SetOption("promisor", "true").
+		SetOption("partialclonefilter", string(partialCloneFilter))
+	return r.SetConfig(cfg)
+}
+
+// fetchBlob fetches a single blob omitted from a partial clone.
+//
+// Note: The remote repository must allow requests for objects that aren't the tips of references (as is the case for
+// GitHub and GitLab, and for git when uploadpack.allowAnySHA1InWant is set).
+func (a Git) fetchBlob(ctx context.Context, r *git.Repository, repo string, hash plumbing.Hash) error {
+	log.Debugf("fetching blob: %v of partial clone: %v", hash, repo)
+	err := withAuth0(&a, repo, func(auth transport.AuthMethod, url string) error {
+		s, err := uploadPackSession(url, auth)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = s.Close() }()
+
+		ar, err := s.AdvertisedReferencesContext(ctx)
+		if err != nil {
+			return err
+		}
+		return fetchPack(ctx, s, ar, r.Storer, []plumbing.Hash{hash}, 0, "")
+	})
+	if err != nil {
+		return fmt.Errorf("error fetching blob: %v: %w", hash, err)
+	}
+	return nil
+}
+
+// showPartial fetches the blob of the file within the commit if it has been omitted from a partial clone.
+func (a Git) showPartial(ctx context.Context, r *git.Repository, repo string, commit *object.Commit, file string) error {
+	if !IsPartial(r) {
+		return nil
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+	entry, err := tree.FindEntry(file)
+	if err != nil {
+		return nil // Reported when the file is shown.
+	}
+	if err := r.Storer.HasEncodedObject(entry.Hash); !errors.Is(err, plumbing.ErrObjectNotFound) {
+		return err
+	}
+	return a.fetchBlob(ctx, r, repo, entry.Hash)
+}
+
+func uploadPackSession(url string, auth transport.AuthMethod) (transport.UploadPackSession, error) {
+	ep, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, err
+	}
+	c, err := client.NewClient(ep)
+	if err != nil {
+		return nil, err
+	}
+	return c.NewUploadPackSession(ep, auth)
+}
+
+// fetchPack requests the wanted objects (and their history to the given depth) from the remote repository, omitting
+// the objects excluded by the filter (if any), and writes the received objects to the storer.
+func fetchPack(ctx context.Context, s transport.UploadPackSession, ar *packp.AdvRefs, st storage.Storer,
+	wants []plumbing.Hash, depth int, filter packp.Filter) (err error) {
+	req := packp.NewUploadPackRequestFromCapabilities(ar.Capabilities)
+	req.Wants = wants
+	if depth != 0 {
+		req.Depth = packp.DepthCommits(depth)
+		if err := req.Capabilities.Set(capability.Shallow); err != nil {
+			return err
+		}
+		if req.Shallows, err = st.Shallow(); err != nil {
+			return err
+		}
+	}
+	if filter != "" {
+		req.Filter = filter
+		if err := req.Capabilities.Set(capability.Filter); err != nil {
+			return err
+		}
+	}
+
+	resp, err := s.UploadPack(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := resp.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	if depth != 0 && len(resp.Shallows)+len(resp.Unshallows) > 0 {
+		if err := updateShallows(st, resp.ShallowUpdate); err != nil {
+			return err
+		}
+	}
+
+	logWriter := log.StandardLogger().Writer()
+	defer func() { _ = logWriter.Close() }()
+	return packfile.UpdateObjectStorage(st, sidebandReader(req.Capabilities, resp, logWriter))
+}
+
+// updateShallows records the commits at the boundary of the fetched history, removing those that have been deepened.
+func updateShallows(st storage.Storer, update packp.ShallowUpdate) error {
+	known, err := st.Shallow()
+	if err != nil {
+		return err
+	}
+	known = slices.DeleteFunc(known, func(h plumbing.Hash) bool { return slices.Contains(update.Unshallows, h) })
+	for _, s := range update.Shallows {
+		if !slices.Contains(known, s) {
+			known = append(known, s)
+		}
+	}
+	return st.SetShallow(known)
+}
+
+// sidebandReader demultiplexes the packfile from the progress messages of the response (if requested).
+func sidebandReader(caps *capability.List, r io.Reader, progress sideband.Progress) io.Reader {
+	var t sideband.Type
+	switch {
+	case caps.Supports(capability.Sideband):
+		t = sideband.Sideband
+	case caps.Supports(capability.Sideband64k):
+		t = sideband.Sideband64k
+	default:
+		return r
+	}
+	d := sideband.NewDemuxer(t, r)
+	d.Progress = progress
+	return d
+}
@@ -0,0 +1,130 @@
+package git
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anz-bank/golden-retriever/retriever"
+)
+
+// partialRepo returns a local repository whose upload-pack supports (or not) filtering objects.
+func partialRepo(t *testing.T, allowFilter bool) (string, []string) {
+	dir, hashes := initLocalRepo(t, "v1")
+	hashes = append(hashes, commitLocalRepo(t, dir, "data/large.bin", "large content"))
+	if allowFilter {
+		require.NoError(t, execute(dir, "git", "config", "uploadpack.allowFilter", "true"))
+		require.NoError(t, execute(dir, "git", "config", "uploadpack.allowAnySHA1InWant", "true"))
+	}
+	return dir, hashes
+}
+
+// hasBlob reports whether the blob of the file at the head of the repository is stored locally.
+func hasBlob(t *testing.T, r *git.Repository, file string) bool {
+	head, err := r.ResolveRevision("main")
+	require.NoError(t, err)
+	commit, err := r.CommitObject(*head)
+	require.NoError(t, err)
+	tree, err := commit.Tree()
+	require.NoError(t, err)
+	entry, err := tree.FindEntry(file)
+	require.NoError(t, err)
+	return r.Storer.HasEncodedObject(entry.Hash) == nil
+}
+
+func TestGitRetrieve_Partial(t *testing.T) {
+	dir, hashes := partialRepo(t, true)
+	cacher := NewPlainFscache(t.TempDir())
+	newGit := func() *Git {
+		return NewWithOptions(&NewGitOptions{AuthOptions: &AuthOptions{Local: true}, Cacher: cacher, PartialClone: true})
+	}
+	retrieve := func(g *Git, file string, ref *retriever.Reference) string {
+		b, err := g.Retrieve(context.Background(), &retriever.Resource{Repo: dir, Filepath: file, Ref: ref})
+		require.NoError(t, err)
+		return string(b)
+	}
+	cached := func() *git.Repository {
+		r, ok := cacher.Get(dir)
+		require.True(t, ok)
+		return r
+	}
+
+	g := newGit()
+	require.Equal(t, "v1", retrieve(g, "README.md", retriever.HEADReference()))
+	require.True(t, IsPartial(cached()))
+	require.False(t, hasBlob(t, cached(), "data/large.bin"))
+
+	// Blobs are fetched on demand.
+	require.Equal(t, "large content", retrieve(g, "data/large.bin", retriever.NewBranchReference("main")))
+	require.True(t, hasBlob(t, cached(), "data/large.bin"))
+
+	// New commits are fetched without blobs.
+	commitLocalRepo(t, dir, "README.md", "v2")
+	g = newGit()
+	require.Equal(t, "v2", retrieve(g, "README.md", retriever.NewBranchReference("main")))
+	require.Equal(t, "v1", retrieve(g, "README.md", hashRef(t, hashes[0])))
+}
+
+func TestGitCloneWithOpts_Partial_Memory(t *testing.T) {
+	dir, _ := partialRepo(t, true)
+	g := NewWithCache(&AuthOptions{Local: true}, NewMemcache())
+
+	resource := &retriever.Resource{Repo: dir, Filepath: "data/large.bin", Ref: retriever.NewBranchReference("main")}
+	r, err := g.CloneWithOpts(context.Background(), resource, CloneOpts{Depth: 1, Partial: true})
+	require.NoError(t, err)
+	require.True(t, IsPartial(r))
+	require.False(t, hasBlob(t, r, "data/large.bin"))
+
+	b, err := g.Show(r, resource)
+	require.NoError(t, err)
+	require.Equal(t, "large content", string(b))
+	require.True(t, hasBlob(t, r, "data/large.bin"))
+}
+
+func TestGitRetrieve_Partial_Unsupported(t *testing.T) {
+	dir, _ := partialRepo(t, false)
+	cacher := NewPlainFscache(t.TempDir())
+	g := NewWithOptions(&NewGitOptions{AuthOptions: &AuthOptions{Local: true}, Cacher: cacher, PartialClone: true})
+
+	b, err := g.Retrieve(context.Background(), &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: retriever.HEADReference()})
+	require.NoError(t, err)
+	require.Equal(t, "v1", string(b))
+
+	// The remote repository doesn't support filters, so the clone is complete.
+	r, ok := cacher.Get(dir)
+	require.True(t, ok)
+	require.False(t, IsPartial(r))
+	require.True(t, hasBlob(t, r, "data/large.bin"))
+}
+
+func TestGitCloneRepo_Partial(t *testing.T) {
+	dir, hashes := partialRepo(t, true)
+	g := NewWithCache(&AuthOptions{Local: true}, NewPlainFscache(t.TempDir()))
+
+	r, err := g.CloneRepo(context.Background(), dir, CloneOpts{Depth: 1, Partial: true})
+	require.NoError(t, err)
+	require.True(t, IsPartial(r.r))
+	require.False(t, hasBlob(t, r.r, "data/large.bin"))
+
+	h, err := r.ResolveHash("main")
+	require.NoError(t, err)
+	require.Equal(t, hashes[1], h)
+	require.Equal(t, plumbing.ReferenceName("refs/heads/main"), headTarget(t, r.r))
+}
+
+func headTarget(t *testing.T, r *git.Repository) plumbing.ReferenceName {
+	head, err := r.Reference(plumbing.HEAD, false)
+	require.NoError(t, err)
+	return head.Target()
+}
+
+func hashRef(t *testing.T, s string) *retriever.Reference {
+	h, err := retriever.NewHash(s)
+	require.NoError(t, err)
+	ref, err := retriever.NewHashReference(h)
+	require.NoError(t, err)
+	return ref
+}
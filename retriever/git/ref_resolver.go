@@ -0,0 +1,172 @@
+package git
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// RefResolver resolves a reference of a remote repository (e.g. a branch, tag, short hash or arbitrary commit) to the
+// full hash of its commit without fetching the repository, typically via the REST API of the hosting service.
+type RefResolver interface {
+	// ResolveRef returns the full commit hash of the reference within the repository at the given path (e.g. org/repo).
+	// The credential of the host is given if one is known, otherwise it is nil.
+	ResolveRef(ctx context.Context, path, ref string, cred *Credential) (string, error)
+}
+
+// DefaultRefResolvers returns the resolvers used for the well known hosting services, keyed by host.
+func DefaultRefResolvers() map[string]RefResolver {
+	return map[string]RefResolver{
+		"github.com":    &GitHubResolver{},
+		"gitlab.com":    &GitLabResolver{},
+		"bitbucket.org": &BitbucketResolver{},
+	}
+}
+
+// GitHubResolver resolves references using the GitHub REST API.
+type GitHubResolver struct {
+	BaseURL string       // The base url of the API, https://api.github.com by default.
+	Client  *http.Client // The client used to make requests, http.DefaultClient by default.
+}
+
+func (g *GitHubResolver) ResolveRef(ctx context.Context, path, ref string, cred *Credential) (string, error) {
+	u := baseURL(g.BaseURL, "https://api.github.com") + "/repos/" + path + "/commits/" + url.PathEscape(ref)
+	var commit struct {
+		SHA string `json:"sha"`
+	}
+	err := getJSON(ctx, g.Client, u, &commit, func(req *http.Request) {
+		req.Header.Set("Accept", "application/vnd.github+json")
+		if cred != nil {
+			req.SetBasicAuth(cred.Username, cred.Password)
+		} else if token := githubToken(); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	})
+	return commit.SHA, err
+}
+
+// githubToken returns the token of the environment as used by the gh cli, if any.
+func githubToken() string {
+	if token := os.Getenv("GH_TOKEN"); token != "" {
+		return token
+	}
+	return os.Getenv("GITHUB_TOKEN")
+}
+
+// GitLabResolver resolves references using the GitLab REST API. Only credentials of tokens are sent, i.e. access tokens
+// (see AuthOptions.WithTokens) and OAuth tokens (of user oauth2), as the API doesn't accept passwords.
+type GitLabResolver struct {
+	BaseURL string       // The base url of the GitLab instance, https://gitlab.com by default.
+	Client  *http.Client // The client used to make requests, http.DefaultClient by default.
+}
+
+func (g *GitLabResolver) ResolveRef(ctx context.Context, path, ref string, cred *Credential) (string, error) {
+	u := baseURL(g.BaseURL, "https://gitlab.com") +
+		"/api/v4/projects/" + url.PathEscape(path) + "/repository/commits/" + url.PathEscape(ref)
+	var commit struct {
+		ID string `json:"id"`
+	}
+	err := getJSON(ctx, g.Client, u, &commit, func(req *http.Request) {
+		if cred == nil {
+			return
+		}
+		// Only tokens are sent, as the API doesn't accept passwords (which mustn't be sent as tokens).
+		switch cred.Username {
+		case tokenUsername:
+			req.Header.Set("PRIVATE-TOKEN", cred.Password)
+		case "oauth2":
+			req.Header.Set("Authorization", "Bearer "+cred.Password)
+		}
+	})
+	return commit.ID, err
+}
+
+// BitbucketResolver resolves references using the Bitbucket Cloud REST API.
+type BitbucketResolver struct {
+	BaseURL string       // The base url of the API, https://api.bitbucket.org by default.
+	Client  *http.Client // The client used to make requests, http.DefaultClient by default.
+}
+
+func (b *BitbucketResolver) ResolveRef(ctx context.Context, path, ref string, cred *Credential) (string, error) {
+	u := baseURL(b.BaseURL, "https://api.bitbucket.org") + "/2.0/repositories/" + path + "/commit/" + url.PathEscape(ref)
+	var commit struct {
+		Hash string `json:"hash"`
+	}
+	err := getJSON(ctx, b.Client, u, &commit, func(req *http.Request) {
+		if cred != nil {
+			req.SetBasicAuth(cred.Username, cred.Password)
+		}
+	})
+	return commit.Hash, err
+}
+
+func baseURL(u, def string) string {
+	if u == "" {
+		return def
+	}
+	return strings.TrimSuffix(u, "/")
+}
+
+// getJSON requests the url and decodes the JSON response into v.
+func getJSON(ctx context.Context, client *http.Client, u string, v any, prepare func(*http.Request)) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	prepare(req)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error requesting: %v: %v", u, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("error decoding response of: %v: %w", u, err)
+	}
+	return nil
+}
+
+// splitRepo splits the repository (e.g. github.com/org/repo) into its host and path.
+func splitRepo(repo string) (string, string) {
+	host, path, _ := strings.Cut(strings.TrimPrefix(repo, "/"), "/")
+	return host, path
+}
+
+// resolveRef resolves the reference of the repository to a full commit hash using the resolver of its host.
+func (a Git) resolveRef(ctx context.Context, repo, ref string) (string, error) {
+	if a.offline {
+		return "", errOffline("resolving reference of", repo)
+	}
+	host, path := splitRepo(repo)
+	resolver := a.refResolvers[host]
+	if resolver == nil {
+		return "", fmt.Errorf("no reference resolver for host: %v", host)
+	}
+	hash, err := resolver.ResolveRef(ctx, path, ref, a.credential(repo))
+	if err != nil {
+		return "", fmt.Errorf("error resolving reference: %v of repo: %v: %w", ref, repo, err)
+	}
+	return hash, nil
+}
+
+// credential returns the first username and password (or token) known for the repository, if any.
+func (a Git) credential(repo string) *Credential {
+	for _, meth := range a.authMethods {
+		auth, _ := meth.AuthMethod(repo)
+		if basicAuth, ok := auth.(*githttp.BasicAuth); ok {
+			return &Credential{Username: basicAuth.Username, Password: basicAuth.Password}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const resolvedHash = "1e7c4cecaaa8f76e3c668cebc411f1b03171501f"
+
+func TestRefResolvers(t *testing.T) {
+	tests := []struct {
+		name     string
+		resolver func(baseURL string) RefResolver
+		path     string
+		body     string
+		checkReq func(t *testing.T, r *http.Request)
+	}{
+		{
+			name:     "github",
+			resolver: func(u string) RefResolver { return &GitHubResolver{BaseURL: u} },
+			path:     "/repos/org/repo/commits/feature%2Fx",
+			body:     `{"sha": "` + resolvedHash + `"}`,
+			checkReq: func(t *testing.T, r *http.Request) {
+				user, pass, ok := r.BasicAuth()
+				require.True(t, ok)
+				require.Equal(t, "user", user)
+				require.Equal(t, "token", pass)
+			},
+		},
+		{
+			name:     "gitlab",
+			resolver: func(u string) RefResolver { return &GitLabResolver{BaseURL: u} },
+			path:     "/api/v4/projects/org%2Frepo/repository/commits/feature%2Fx",
+			body:     `{"id": "` + resolvedHash + `"}`,
+			checkReq: func(t *testing.T, r *http.Request) {
+				// Passwords aren't sent as tokens.
+				require.Empty(t, r.Header.Get("PRIVATE-TOKEN"))
+				require.Empty(t, r.Header.Get("Authorization"))
+			},
+		},
+		{
+			name:     "bitbucket",
+			resolver: func(u string) RefResolver { return &BitbucketResolver{BaseURL: u} },
+			path:     "/2.0/repositories/org/repo/commit/feature%2Fx",
+			body:     `{"hash": "` + resolvedHash + `"}`,
+			checkReq: func(t *testing.T, r *http.Request) {
+				user, pass, ok := r.BasicAuth()
+				require.True(t, ok)
+				require.Equal(t, "user", user)
+				require.Equal(t, "token", pass)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.EscapedPath() != test.path {
+					http.NotFound(w, r)
+					return
+				}
+				test.checkReq(t, r)
+				_, _ = fmt.Fprint(w, test.body)
+			}))
+			defer server.Close()
+
+			resolver := test.resolver(server.URL)
+			hash, err := resolver.ResolveRef(context.Background(), "org/repo", "feature/x", &Credential{"user", "token"})
+			require.NoError(t, err)
+			require.Equal(t, resolvedHash, hash)
+
+			_, err = resolver.ResolveRef(context.Background(), "org/missing", "feature/x", nil)
+			require.ErrorContains(t, err, "404 Not Found")
+		})
+	}
+}
+
+func TestGitLabResolver_Tokens(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		_, _ = fmt.Fprint(w, `{"id": "`+resolvedHash+`"}`)
+	}))
+	defer server.Close()
+	resolver := &GitLabResolver{BaseURL: server.URL}
+
+	_, err := resolver.ResolveRef(context.Background(), "org/repo", "main", &Credential{tokenUsername, "token"})
+	require.NoError(t, err)
+	require.Equal(t, "token", header.Get("PRIVATE-TOKEN"))
+	require.Empty(t, header.Get("Authorization"))
+
+	_, err = resolver.ResolveRef(context.Background(), "org/repo", "main", &Credential{"oauth2", "token"})
+	require.NoError(t, err)
+	require.Equal(t, "Bearer token", header.Get("Authorization"))
+	require.Empty(t, header.Get("PRIVATE-TOKEN"))
+}
+
+// stubResolver resolves references to the hashes of the map.
+type stubResolver map[string]string
+
+func (s stubResolver) ResolveRef(_ context.Context, _, ref string, _ *Credential) (string, error) {
+	if h, ok := s[ref]; ok {
+		return h, nil
+	}
+	return "", fmt.Errorf("unknown reference: %v", ref)
+}
+
+func TestRepo_FetchRefOrAll_RefResolver(t *testing.T) {
+	dir, hashes := initLocalRepo(t, "v1", "v2")
+	require.NoError(t, execute(dir, "git", "config", "uploadpack.allowReachableSHA1InWant", "true"))
+
+	host, _ := splitRepo(dir)
+	g := NewWithOptions(&NewGitOptions{
+		AuthOptions:  &AuthOptions{Local: true},
+		Cacher:       NewPlainFscache(t.TempDir()),
+		RefResolvers: map[string]RefResolver{host: stubResolver{"v1": hashes[0]}},
+	})
+	r, err := g.CloneRepo(context.Background(), dir, CloneOpts{Depth: 1, NoCheckout: true})
+	require.NoError(t, err)
+
+	// The reference isn't known to the remote, so it's resolved and then fetched by hash.
+	err = r.FetchRefOrAll(context.Background(), "v1", FetchOpts{Depth: 1})
+	require.NoError(t, err)
+	exists, err := r.Exists(hashes[0])
+	require.NoError(t, err)
+	require.True(t, exists)
+}
+
+func TestNewWithOptions_RefResolvers(t *testing.T) {
+	custom := &GitLabResolver{BaseURL: "https://gitlab.example.com"}
+	g := NewWithOptions(&NewGitOptions{
+		RefResolvers: map[string]RefResolver{"gitlab.example.com": custom, "bitbucket.org": nil},
+	})
+	require.Equal(t, custom, g.refResolvers["gitlab.example.com"])
+	require.IsType(t, &GitHubResolver{}, g.refResolvers["github.com"])
+	require.NotContains(t, g.refResolvers, "bitbucket.org")
+
+	_, err := g.resolveRef(context.Background(), "bitbucket.org/org/repo", "main")
+	require.ErrorContains(t, err, "no reference resolver for host: bitbucket.org")
+}
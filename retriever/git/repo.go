@@ -4,15 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os/exec"
-	"strings"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/transport"
-	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	log "github.com/sirupsen/logrus"
+
+	"github.com/anz-bank/golden-retriever/retriever"
 )
 
 type Repo struct {
@@ -28,6 +27,9 @@ func (r *Repo) String() string {
 // InitWithRemote initialises a plain repository at the directory for the given repository, adding the appropriate remote.
 func (a Git) InitWithRemote(_ context.Context, repo string) (*Repo, error) {
 	log.Debugf("initialising repo: %v", repo)
+	if a.offline {
+		return nil, errOffline("initialising", repo)
+	}
 	c, plain := a.cacher.(PlainFsCache)
 	if !plain {
 		return nil, fmt.Errorf("repository must be a plain repository")
@@ -58,6 +60,13 @@ func (a Git) CloneRepo(ctx context.Context, repo string, opts CloneOpts) (*Repo,
 	if !plain {
 		return nil, fmt.Errorf("repository must be a plain repository")
 	}
+	if opts.Partial {
+		r, err := a.clonePartial(ctx, &retriever.Resource{Repo: repo, Ref: retriever.HEADReference()}, opts.Depth)
+		if err != nil {
+			return nil, err
+		}
+		return &Repo{&a, r, repo}, nil
+	}
 	tags := opts.Tags.TagMode(git.AllTags)
 	r, err := withAuth1(&a, repo, func(auth transport.AuthMethod, url string) (*git.Repository, error) {
 		return git.PlainCloneContext(ctx, c.RepoDir(repo), false, &git.CloneOptions{
@@ -75,30 +84,27 @@ func (a Git) CloneRepo(ctx context.Context, repo string, opts CloneOpts) (*Repo,
 }
 
 // FetchRefOrAll fetches the reference from the remote repository, falling back to attempting to resolve the reference
-// using the gh cli, if that fails it fetches the entire repo.
+// using the RefResolver of the host of the repository, if that fails it fetches the entire repo.
 func (r *Repo) FetchRefOrAll(ctx context.Context, ref string, opts FetchOpts) error {
 	err := r.FetchRef(ctx, ref, opts)
 	if err == nil {
 		return nil
 	}
 
-	// If hitting github.com then try to expand the ref to a hash using the github API (via the gh command-line)
 	var err2, resolveErr error
-	if strings.HasPrefix(r.repo, "github.com") {
-		cmd := exec.Command("gh", "api", "/repos/"+r.repo[11:]+"/commits/"+ref, "--jq", ".sha")
-		cmd.Env = append(cmd.Environ(), "GH_NO_UPDATE_NOTIFIER=TRUE")
-		// Check if there is a gihub token in authmethods
-		for _, meth := range r.g.authMethods {
-			githubAuth, _ := meth.AuthMethod("github.com")
-			if basicAuth, ok := githubAuth.(*http.BasicAuth); ok {
-				cmd.Env = append(cmd.Env, "GH_TOKEN="+basicAuth.Password)
-				break
-			}
+	if _, err := retriever.NewShortHashReference(ref); err == nil {
+		// Search for short hashes (locally, via the API of the host, then within progressively deeper history)
+		_, err2 = r.g.findShortHash(ctx, r.r, r.repo, ref)
+		var ambiguous *AmbiguousHashError
+		if err2 == nil || errors.As(err2, &ambiguous) {
+			return err2
 		}
-		var out []byte
-		out, resolveErr = cmd.Output()
+	} else {
+		// Try to expand the ref to a hash using the API of the host (e.g. the github API)
+		var hash string
+		hash, resolveErr = r.g.resolveRef(ctx, r.repo, ref)
 		if resolveErr == nil {
-			err2 = r.FetchRef(ctx, strings.TrimSpace(string(out)), opts)
+			err2 = r.FetchRef(ctx, hash, opts)
 			if err2 == nil {
 				return nil
 			}
@@ -120,7 +126,7 @@ func (r *Repo) FetchRefOrAll(ctx context.Context, ref string, opts FetchOpts) er
 		return fmt.Errorf("error fetching ref or all, ref: %v doesn't exist after fetch: %w", ref, err)
 	}
 	if resolveErr != nil {
-		log.Infof("reference resolved after full fetch, to enable resolving the reference via API and not requiring a full fetch, install gh and either set GH_TOKEN or call 'gh auth login': %v", resolveErr)
+		log.Infof("reference resolved after full fetch, to enable resolving the reference via API and not requiring a full fetch, configure a reference resolver (and credentials) for the host: %v", resolveErr)
 	}
 	return nil
 }
@@ -129,7 +135,7 @@ func (r *Repo) FetchRefOrAll(ctx context.Context, ref string, opts FetchOpts) er
 //
 // Note: This function does not support short hashes (e.g. 1e7c4cec) due to the following failure:
 // couldn't find remote ref
-// Full hash values must be used in their place.
+// Full hash values must be used in their place, or use FetchRefOrAll which resolves short hashes.
 func (r *Repo) FetchRef(ctx context.Context, ref string, opts FetchOpts) error {
 	spec := config.RefSpec(fmt.Sprintf("+%s:%[1]s", ref))
 	log.Debugf("fetching ref: %v from repo: %v with spec: %v and opts: %v", ref, r, spec, opts)
@@ -199,11 +205,12 @@ func (r *Repo) ListRemoteRefs(ctx context.Context, remoteName string, opts ListO
 }
 
 type CheckoutOpts struct {
-	Force bool
+	Force  bool
+	Sparse []string // The directories to check out, or all directories if empty.
 }
 
 func (o CheckoutOpts) String() string {
-	return fmt.Sprintf("{Force:%v}", o.Force)
+	return fmt.Sprintf("{Force:%v, Sparse:%v}", o.Force, o.Sparse)
 }
 
 // Checkout checks out the repository at the given reference.
@@ -214,6 +221,16 @@ func (r *Repo) Checkout(ref string, opts CheckoutOpts) error {
 		return fmt.Errorf("error resolving revision in repo: %v for reference: %v: %w", r, ref, err)
 	}
 
+	// Sparse checkouts (and restoring a full checkout from a sparse one) are handled separately.
+	sparse := cleanSparse(opts.Sparse)
+	current, err := r.Sparse()
+	if err != nil {
+		return err
+	}
+	if len(sparse) > 0 || len(current) > 0 {
+		return r.sparseCheckout(*hash, sparse, opts.Force)
+	}
+
 	worktree, err := r.r.Worktree()
 	if err != nil {
 		return err
@@ -259,11 +276,15 @@ func (r *Repo) Exists(ref string) (bool, error) {
 }
 
 func withAuth0(g *Git, repo string, f func(auth transport.AuthMethod, url string) error) error {
+	if g.offline {
+		return errOffline("contacting", repo)
+	}
 	var errs []error
 	for _, meth := range g.authMethods {
 		auth, url := meth.AuthMethod(repo)
 		err := f(auth, url)
 		if err == nil {
+			g.setServed(repo, meth, url)
 			return nil
 		} else {
 			errs = append(errs, fmt.Errorf("error executing operation with auth: %v: %w", meth.Name(), err))
@@ -273,11 +294,15 @@ func withAuth0(g *Git, repo string, f func(auth transport.AuthMethod, url string
 }
 
 func withAuth1[T any](g *Git, repo string, f func(auth transport.AuthMethod, url string) (*T, error)) (*T, error) {
+	if g.offline {
+		return nil, errOffline("contacting", repo)
+	}
 	var errs []error
 	for _, meth := range g.authMethods {
 		auth, url := meth.AuthMethod(repo)
 		t, err := f(auth, url)
 		if err == nil {
+			g.setServed(repo, meth, url)
 			return t, nil
 		} else {
 			errs = append(errs, fmt.Errorf("error executing operation with auth: %v: %w", meth.Name(), err))
@@ -2,8 +2,10 @@ package git
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -25,23 +27,44 @@ type Git struct {
 	once        once.Once
 
 	noForcedFetch bool
-	fetchedRefs   *sync.Map
+	freshness     FreshnessPolicy
+	tagPolicy     retriever.TagPolicy
+	offline       bool
+	partialClone  bool
+	refResolvers  map[string]RefResolver
+	fetchedRefs   *sync.Map // The times references were fetched at, keyed by repository and reference.
+	checkedTags   *sync.Map // The tags checked against the remote repository, keyed by repository and tag.
+	served        *sync.Map // The remote (and authentication method) last fetched from, keyed by repository.
+	worktrees     *worktrees
 }
 
 // New returns new Git with given authentication parameters. Cache repositories in memory by default.
 func New(options *AuthOptions) *Git {
-	return NewWithOptions(&NewGitOptions{options, NewMemcache(), false})
+	return NewWithOptions(&NewGitOptions{AuthOptions: options, Cacher: NewMemcache()})
 }
 
 // NewWithCache returns new Git with given authentication parameters and git cacher.
 func NewWithCache(options *AuthOptions, cacher Cacher) *Git {
-	return NewWithOptions(&NewGitOptions{options, cacher, false})
+	return NewWithOptions(&NewGitOptions{AuthOptions: options, Cacher: cacher})
 }
 
 type NewGitOptions struct {
 	AuthOptions   *AuthOptions
 	Cacher        Cacher
 	NoForcedFetch bool
+	PartialClone  bool // True to clone repositories without blobs, fetching the content of files when retrieved.
+	Offline       bool // True to never contact remote repositories, retrieving resources from the cache only (see ErrOffline).
+
+	// Freshness describes how long fetched branches (and HEAD) are retrieved for without being fetched again.
+	Freshness FreshnessPolicy
+
+	// TagPolicy describes how to handle tags that have moved since they were cached, which are only checked against the
+	// remote repository (once per Git) if the policy isn't retriever.TagPolicyTrust (the default).
+	TagPolicy retriever.TagPolicy
+
+	// RefResolvers resolve references that can't be fetched directly (e.g. short hashes) via the API of the host,
+	// keyed by host. They are added to (or replace, if nil) those of DefaultRefResolvers.
+	RefResolvers map[string]RefResolver
 }
 
 // NewWithOptions returns new Git with given options.
@@ -71,7 +94,7 @@ func NewWithOptions(options *NewGitOptions) *Git {
 			creds := make(map[string]Credential, len(options.AuthOptions.Tokens))
 			for host, token := range options.AuthOptions.Tokens {
 				creds[host] = Credential{
-					Username: "modv2",
+					Username: tokenUsername,
 					Password: token,
 				}
 			}
@@ -84,7 +107,7 @@ func NewWithOptions(options *NewGitOptions) *Git {
 			creds := make(map[string]Credential, len(options.AuthOptions.tokens))
 			for host, tokens := range options.AuthOptions.tokens {
 				creds[host] = Credential{
-					Username: "modv2",
+					Username: tokenUsername,
 					Password: tokens[0],
 				}
 				// BasicAuth can only handle a single token for each host, so use the same map for each host
@@ -94,7 +117,7 @@ func NewWithOptions(options *NewGitOptions) *Git {
 						extraMethods = append(extraMethods, NewBasicAuth(
 							map[string]Credential{
 								host: {
-									Username: "modv2",
+									Username: tokenUsername,
 									Password: token,
 								},
 							},
@@ -113,14 +136,41 @@ func NewWithOptions(options *NewGitOptions) *Git {
 		methods = append(methods, Local{})
 	}
 
+	resolvers := DefaultRefResolvers()
+	for host, resolver := range options.RefResolvers {
+		if resolver == nil {
+			delete(resolvers, host)
+		} else {
+			resolvers[host] = resolver
+		}
+	}
+
 	return &Git{
 		authMethods: methods,
 		cacher:      options.Cacher,
 		once:        once.NewOnce(),
 
 		noForcedFetch: options.NoForcedFetch,
+		freshness:     options.Freshness,
+		tagPolicy:     options.TagPolicy,
+		offline:       options.Offline,
+		partialClone:  options.PartialClone,
+		refResolvers:  resolvers,
 		fetchedRefs:   &sync.Map{},
+		checkedTags:   &sync.Map{},
+		served:        &sync.Map{},
+		worktrees:     newWorktrees(),
+	}
+}
+
+// Local reports whether local repositories (directories) may be retrieved, i.e. the Local authenticator is available.
+func (a Git) Local() bool {
+	for _, meth := range a.authMethods {
+		if _, ok := meth.(Local); ok {
+			return true
+		}
 	}
+	return false
 }
 
 // AuthOptions describes which authentication methods are available.
@@ -182,6 +232,8 @@ type SetOpts struct {
 	Checkout OptCheckout // How to check out (or not) the state of repositories.
 	Depth    int         // The depth at which to fetch remote content (if required).
 	Verify   bool        // True to verify the repository is already at the requested reference (returning an error if it's not).
+	Worktree bool        // True to check out the reference into its own worktree rather than the repository directory.
+	Sparse   []string    // The directories to check out (e.g. api), or all directories if empty.
 }
 
 // OptFetch describes how to fetch content from remote repositories.
@@ -248,12 +300,13 @@ func (f OptCheckout) String() string {
 }
 
 func (o SetOpts) String() string {
-	return fmt.Sprintf("{Fetch:%v, Reset:%v, Depth:%v}",
-		o.Fetch, o.Reset, o.Depth)
+	return fmt.Sprintf("{Fetch:%v, Reset:%v, Depth:%v, Worktree:%v, Sparse:%v}",
+		o.Fetch, o.Reset, o.Depth, o.Worktree, o.Sparse)
 }
 
 type SetResult struct {
 	Commit *object.Commit // The commit that the repository was set to.
+	Dir    string         // The directory the repository was set in (or the worktree directory).
 }
 
 // Set the repository to the given reference, resetting as necessary.
@@ -273,28 +326,39 @@ type SetResult struct {
 // 3. Short hashes:     e.g. 1e7c4cec
 // 4. Tags:         	e.g. v0.0.1
 // 5. Prefixed tags:    e.g. tags/v0.0.1 [legacy behaviour]
+//
+// If a worktree is requested, the repository itself is not checked out. Instead the reference is checked out into a
+// directory of its own (shared by all requests for the same commit) which is returned in the result, and must be
+// released with ReleaseWorktree when no longer required.
 func (a Git) Set(ctx context.Context, repo, ref string, opts SetOpts) (*SetResult, error) {
 	log.Debugf("setting repo: %v to reference: %v with opts: %v", repo, ref, opts)
+	opts.Fetch = a.fetchOpt(opts.Fetch)
+	if opts.Worktree {
+		return a.setWorktree(ctx, repo, ref, opts)
+	}
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	default:
-		ch := a.once.Register(repo)
+		a.once.Wait(repo)
 		defer a.once.Unregister(repo)
-		if ch != nil {
-			<-ch
-		}
 
 		// Cache the git repository object.
 		rr, ok := a.cacher.Get(repo)
 
+		// Cache the directory of the repository.
+		dir := ""
+		if c, plain := a.cacher.(PlainFsCache); plain {
+			dir = c.RepoDir(repo)
+		}
+
 		// Cache a function to return the set result at the given hash.
 		resultAt := func(r *Repo, hash string) (*SetResult, error) {
 			commit, err := r.r.CommitObject(plumbing.NewHash(hash))
 			if err != nil {
 				return nil, err
 			}
-			return &SetResult{Commit: commit}, nil
+			return &SetResult{Commit: commit, Dir: dir}, nil
 		}
 
 		// Handle the case where the repository has not yet been initialised.
@@ -363,7 +427,8 @@ func (a Git) Set(ctx context.Context, repo, ref string, opts SetOpts) (*SetResul
 
 			// Checkout the repository.
 			err = r.Checkout(ref, CheckoutOpts{
-				Force: true,
+				Force:  true,
+				Sparse: opts.Sparse,
 			})
 			if err != nil {
 				return nil, fmt.Errorf("error checking out reference: %v: %w", ref, err)
@@ -461,12 +526,14 @@ func (a Git) Set(ctx context.Context, repo, ref string, opts SetOpts) (*SetResul
 		// 2. The repository is actually empty, or
 		// 3. The repository has been modified outside of this process.
 		// In any of these scenarios, avoiding an early return does not affect the correctness of the end result.
-		if headHash == refHash && (opts.Reset != OptResetTrue) {
-			c, plain := a.cacher.(PlainFsCache)
-			if !plain {
+		sparse, err := r.Sparse()
+		if err != nil {
+			return nil, fmt.Errorf("error reading sparse checkout: %w", err)
+		}
+		if headHash == refHash && (opts.Reset != OptResetTrue) && slices.Equal(sparse, cleanSparse(opts.Sparse)) {
+			if _, plain := a.cacher.(PlainFsCache); !plain {
 				return nil, fmt.Errorf("repository must be a plain repository")
 			}
-			dir := c.RepoDir(repo)
 			files, err := os.ReadDir(dir)
 			if err != nil {
 				return nil, fmt.Errorf("error reading directory: %v for repository: %v", dir, repo)
@@ -487,7 +554,8 @@ func (a Git) Set(ctx context.Context, repo, ref string, opts SetOpts) (*SetResul
 
 		// Checkout the repository to the requested reference, resetting as necessary.
 		err = r.Checkout(ref, CheckoutOpts{
-			Force: opts.Reset != OptResetFalse,
+			Force:  opts.Reset != OptResetFalse,
+			Sparse: opts.Sparse,
 		})
 		if err != nil {
 			return nil, fmt.Errorf("error checking out reference: %v: %w", ref, err)
@@ -500,11 +568,24 @@ func keyFromResource(resource *retriever.Resource) string {
 	return resource.Repo + ":" + resource.Ref.Name()
 }
 
+// setFetched records the reference of the resource as fetched now, resolving the resource if its reference is HEAD.
+// The fetch times of symbolic references are persisted if the repository is cached in the filesystem.
 func (a Git) setFetched(r *git.Repository, resource *retriever.Resource) {
-	a.fetchedRefs.Store(keyFromResource(resource), true)
+	now := time.Now()
+	var names []string
+	if !resource.Ref.IsHash() {
+		names = append(names, resource.Ref.Name())
+	}
+	a.fetchedRefs.Store(keyFromResource(resource), now)
 	if resource.Ref.IsHEAD() {
-		_ = a.ResolveReference(r, resource)
-		a.fetchedRefs.Store(keyFromResource(resource), true)
+		if ref, err := a.Resolve(r, resource.Ref); err == nil {
+			resource.Ref = ref
+			names = append(names, ref.Name())
+		}
+		a.fetchedRefs.Store(keyFromResource(resource), now)
+	}
+	if err := a.writeFetchTimes(resource.Repo, names, now); err != nil {
+		log.Debugf("error writing fetch times of repo: %v: %s", resource.Repo, err)
 	}
 }
 
@@ -516,7 +597,59 @@ func (a Git) isFetched(resource *retriever.Resource) bool {
 
 // Retrieve remote file in format of <repo>/<filepath>@<ref>, e.g. github.com/org/foo/bar.json@v0.1.0
 // Return the latest content of the file in default branch if no ref specified
-func (a Git) Retrieve(ctx context.Context, resource *retriever.Resource) (c []byte, err error) {
+func (a Git) Retrieve(ctx context.Context, resource *retriever.Resource) ([]byte, error) {
+	result, err := a.RetrieveWithInfo(ctx, resource)
+	if err != nil {
+		return nil, err
+	}
+	return result.Content, nil
+}
+
+// RetrieveWithInfo retrieves the remote file as Retrieve does, returning its content with information about the commit
+// and file it was retrieved from (including the resolved reference), and the remote repository and authentication
+// method (if any) that fetched it. The resource isn't modified.
+func (a Git) RetrieveWithInfo(ctx context.Context, resource *retriever.Resource) (result *retriever.Result, err error) {
+	resource = resource.Clone()
+	if resource.Ref == nil {
+		resource.Ref = retriever.HEADReference()
+	}
+	return a.retrieveWithInfo(ctx, resource)
+}
+
+// RetrieveCommit resolves (cloning or fetching as required) the reference of the resource as RetrieveWithInfo does,
+// returning its commit, e.g. to browse the files of the commit with gitfs.NewGitMemFs. The file of the resource isn't
+// retrieved (so blobs missing from partial clones aren't fetched). The resource isn't modified.
+//
+// Note: Repositories using the SHA-256 object format aren't supported, as go-git doesn't support them.
+func (a Git) RetrieveCommit(ctx context.Context, resource *retriever.Resource) (*object.Commit, error) {
+	resource = resource.Clone()
+	if resource.Ref == nil {
+		resource.Ref = retriever.HEADReference()
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+	a.once.Wait(resource.Repo)
+	defer a.once.Unregister(resource.Repo)
+
+	if a.isSHA256(resource) {
+		return nil, fmt.Errorf("error retrieving commit of resource: %v: %w", resource, errSHA256Advertised)
+	}
+	r, err := a.fetchResource(ctx, resource)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving commit of resource: %v: %w", resource, err)
+	}
+	ref, err := a.Resolve(r, resource.Ref)
+	if err != nil {
+		return nil, err
+	}
+	return r.CommitObject(plumbing.NewHash(ref.Hash().String()))
+}
+
+// retrieveWithInfo retrieves the resource (a copy of that of the caller), resolving its reference.
+func (a Git) retrieveWithInfo(ctx context.Context, resource *retriever.Resource) (result *retriever.Result, err error) {
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
@@ -528,54 +661,124 @@ func (a Git) Retrieve(ctx context.Context, resource *retriever.Resource) (c []by
 
 			// Don't just continue otherwise you could get multiple threads continuing at the same time
 			// Try again, checking for ctx.Done() as well
-			return a.Retrieve(ctx, resource)
+			return a.retrieveWithInfo(ctx, resource)
+		}
+		a.served.Delete(resource.Repo)
+
+		if a.isSHA256(resource) {
+			return a.retrieveSHA256(ctx, resource)
 		}
 
-		r, ok := a.cacher.Get(resource.Repo)
+		r, err := a.fetchResource(ctx, resource)
+		if errors.Is(err, errSHA256Advertised) {
+			return a.retrieveSHA256(ctx, resource)
+		} else if err != nil {
+			return nil, err
+		}
+
+		ref, c, err := a.show(ctx, r, resource)
+		if err != nil {
+			return nil, fmt.Errorf("git show: %w", err)
+		}
+		resource.Ref = ref
+		return a.result(r, resource, c)
+	}
+}
+
+// fetchResource returns the repository of the resource (a copy of that of the caller), cloning or fetching its reference
+// as required, and resolving short hashes and tags. errSHA256Advertised is returned if the repository uses the SHA-256
+// object format.
+func (a Git) fetchResource(ctx context.Context, resource *retriever.Resource) (r *git.Repository, err error) {
+	if a.offline {
+		return a.fetchOffline(ctx, resource)
+	}
+	r, ok := a.cacher.Get(resource.Repo)
+	if resource.Ref.IsShortHash() && !resource.Ref.IsHash() && a.isRefName(ctx, r, resource.Repo, resource.Ref.Name()) {
+		resource.Ref = retriever.NewSymbolicReference(resource.Ref.Name())
+	}
+	if resource.Ref.IsShortHash() && !resource.Ref.IsHash() {
 		if !ok {
-			start := time.Now()
-			log.Debugf(" ===> clone: %s@%s\n", resource.Repo, resource.Ref.Name())
-			// Can't pass {SingleBranch: !resource.Ref.IsHEAD()} because the ref could be a tag
-			r, err = a.CloneWithOpts(ctx, resource, CloneOpts{Depth: 1, NoCheckout: true})
-			log.Debugf(" <=== clone (%s) complete in %s\n", resource.Repo, time.Since(start))
+			// Clone the head of the repository, the commit is then searched for within its history.
+			head := &retriever.Resource{Repo: resource.Repo, Ref: retriever.HEADReference()}
+			r, err = a.CloneWithOpts(ctx, head, CloneOpts{Depth: 1, NoCheckout: true, Partial: a.partialClone})
 			if err != nil {
 				return nil, fmt.Errorf("git clone: %s", err.Error())
 			}
-			a.setFetched(r, resource)
-		} else {
-			if a.noForcedFetch {
-				c, err = a.Show(r, resource)
-				if err == nil {
-					return c, nil
+			ok = true
+		}
+		if err := a.ResolveShortHash(ctx, r, resource); err != nil {
+			return nil, fmt.Errorf("git resolve: %s", err.Error())
+		}
+	}
+	if !ok {
+		start := time.Now()
+		log.Debugf(" ===> clone: %s@%s\n", resource.Repo, resource.Ref.Name())
+		// Can't pass {SingleBranch: !resource.Ref.IsHEAD()} because the ref could be a tag
+		r, err = a.CloneWithOpts(ctx, resource, CloneOpts{Depth: 1, NoCheckout: true, Partial: a.partialClone})
+		log.Debugf(" <=== clone (%s) complete in %s\n", resource.Repo, time.Since(start))
+		if isSHA256AdvertisedErr(err) {
+			return nil, errSHA256Advertised
+		}
+		if err != nil {
+			return nil, fmt.Errorf("git clone: %s", err.Error())
+		}
+		a.setFetched(r, resource)
+	} else {
+		head := resource.Ref.IsHEAD()
+		if a.noForcedFetch && (a.ttl(resource, head) == 0 || a.isFresh(r, resource, head)) {
+			if ref, err := a.present(ctx, r, resource); err == nil {
+				if _, ok := a.ResolveTag(r, resource.Ref); ok {
+					if ref, err = a.checkTag(ctx, r, resource.Repo, ref); err != nil {
+						return nil, err
+					}
 				}
+				resource.Ref = ref
+				return r, nil
 			}
+		}
 
-			if resource.Ref.IsHEAD() {
-				// Resolve HEAD branch but don't keep the current hash
-				_ = a.ResolveReference(r, resource)
-				resource.Ref = retriever.NewBranchReference(resource.Ref.Name())
+		if resource.Ref.IsHEAD() {
+			// Resolve HEAD branch but don't keep the current hash
+			if ref, err := a.Resolve(r, resource.Ref); err == nil {
+				resource.Ref = ref
 			}
+			resource.Ref = retriever.NewBranchReference(resource.Ref.Name())
+		}
 
-			// Check if it's a tag, we assume tags don't change so don't need to refetch
-			if a.TryResolveAsTag(r, resource) {
-				a.setFetched(r, resource)
-			} else if !a.isFetched(resource) {
-				start := time.Now()
-				log.Debugf(" ===> fetching: %s@%s\n", resource.Repo, resource.Ref.Name())
-				err = a.Fetch(ctx, r, resource)
-				log.Debugf(" <=== fetching (%s) complete in %s\n", resource.Repo, time.Since(start))
-				if err != nil {
-					return nil, fmt.Errorf("git fetch: %s", err.Error())
-				}
-
-				a.setFetched(r, resource)
+		// Check if it's a tag, we assume tags don't change so don't need to refetch
+		if ref, ok := a.ResolveTag(r, resource.Ref); ok {
+			if ref, err = a.checkTag(ctx, r, resource.Repo, ref); err != nil {
+				return nil, err
+			}
+			resource.Ref = ref
+			a.setFetched(r, resource)
+		} else if !a.isFresh(r, resource, head) {
+			start := time.Now()
+			log.Debugf(" ===> fetching: %s@%s\n", resource.Repo, resource.Ref.Name())
+			err = a.Fetch(ctx, r, resource)
+			log.Debugf(" <=== fetching (%s) complete in %s\n", resource.Repo, time.Since(start))
+			if err != nil {
+				return nil, fmt.Errorf("git fetch: %s", err.Error())
 			}
-		}
 
-		c, err = a.Show(r, resource)
-		if err != nil {
-			return nil, fmt.Errorf("git show: %s", err.Error())
+			a.setFetched(r, resource)
 		}
-		return c, nil
 	}
+	return r, nil
+}
+
+// present returns the resolved reference of the resource if its commit (and file, if any) is within the repository.
+func (a Git) present(ctx context.Context, r *git.Repository, resource *retriever.Resource) (*retriever.Reference, error) {
+	if resource.Filepath != "" {
+		ref, _, err := a.show(ctx, r, resource)
+		return ref, err
+	}
+	ref, err := a.Resolve(r, resource.Ref)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.CommitObject(plumbing.NewHash(ref.Hash().String())); err != nil {
+		return nil, err
+	}
+	return ref, nil
 }
@@ -0,0 +1,155 @@
+package git
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// SessionEntry is a reference of a repository pinned to a hash within a session.
+type SessionEntry struct {
+	Repo string `json:"repo"`
+	Ref  string `json:"ref"`
+	Hash string `json:"hash"`
+}
+
+// SessionState is a snapshot of the references pinned within a session.
+type SessionState struct {
+	Entries []SessionEntry `json:"entries"`
+}
+
+// sessionRecord is a line of a session journal. A reset record discards all previously recorded entries.
+type sessionRecord struct {
+	SessionEntry
+	Reset bool `json:"reset,omitempty"`
+}
+
+// NewSessionWithFile returns a new Session that loads its state from the given file (if it exists) and records every
+// newly pinned reference to it, so the stability guarantee of the session extends across process restarts.
+func NewSessionWithFile(g *Git, path string) (Session, error) {
+	b, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("error reading session file: %v: %w", path, err)
+	}
+	return newPersistentSession(g, bytes.NewReader(b), appendFile(path))
+}
+
+// NewSessionWithReadWriter returns a new Session that loads its state from the given reader (until EOF) and records
+// every newly pinned reference to it.
+func NewSessionWithReadWriter(g *Git, rw io.ReadWriter) (Session, error) {
+	return newPersistentSession(g, rw, rw)
+}
+
+func newPersistentSession(g *Git, r io.Reader, w io.Writer) (Session, error) {
+	s := newSession(g)
+	dec := json.NewDecoder(r)
+	for {
+		var rec sessionRecord
+		if err := dec.Decode(&rec); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error loading session state: %w", err)
+		}
+		if rec.Reset {
+			s.hashes = make(map[sessionKey]string)
+			continue
+		}
+		s.hashes[sessionKey{rec.Repo, rec.Ref}] = rec.Hash
+	}
+	s.journal = w
+	return s, nil
+}
+
+// record pins the reference of the repository to the given hash, writing it to the journal if it is newly known.
+func (s sessionImpl) record(repo, ref, hash string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := sessionKey{repo, ref}
+	if h, ok := s.hashes[key]; ok && h == hash {
+		return nil
+	}
+	s.hashes[key] = hash
+	return s.write(sessionRecord{SessionEntry: SessionEntry{Repo: repo, Ref: ref, Hash: hash}})
+}
+
+// lookup returns the hash the reference of the repository is pinned to, if any.
+func (s sessionImpl) lookup(repo, ref string) (string, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	h, ok := s.hashes[sessionKey{repo, ref}]
+	return h, ok
+}
+
+func (s sessionImpl) write(records ...sessionRecord) error {
+	if s.journal == nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	if _, err := s.journal.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("error saving session state: %w", err)
+	}
+	return nil
+}
+
+func (s sessionImpl) Entries() []SessionEntry {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	entries := make([]SessionEntry, 0, len(s.hashes))
+	for k, h := range s.hashes {
+		entries = append(entries, SessionEntry{Repo: k.repo, Ref: k.ref, Hash: h})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Repo != entries[j].Repo {
+			return entries[i].Repo < entries[j].Repo
+		}
+		return entries[i].Ref < entries[j].Ref
+	})
+	return entries
+}
+
+func (s sessionImpl) Snapshot() SessionState {
+	return SessionState{Entries: s.Entries()}
+}
+
+func (s sessionImpl) Restore(state SessionState) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for k := range s.hashes {
+		delete(s.hashes, k)
+	}
+	records := []sessionRecord{{Reset: true}}
+	for _, e := range state.Entries {
+		s.hashes[sessionKey{e.Repo, e.Ref}] = e.Hash
+		records = append(records, sessionRecord{SessionEntry: e})
+	}
+	return s.write(records...)
+}
+
+// appendFile is an io.Writer that appends to the file at the path, creating it if necessary.
+type appendFile string
+
+func (f appendFile) Write(p []byte) (int, error) {
+	if err := os.MkdirAll(filepath.Dir(string(f)), os.ModePerm); err != nil {
+		return 0, err
+	}
+	file, err := os.OpenFile(string(f), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, err
+	}
+	n, err := file.Write(p)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return n, err
+}
@@ -0,0 +1,69 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Verify that a session loaded from a file resolves references to the hashes pinned by an earlier session.
+func TestGitSession_File(t *testing.T) {
+	remote, hashes := initLocalRepo(t, "one")
+	g := NewWithCache(&AuthOptions{Local: true}, NewPlainFscache(t.TempDir()))
+	path := filepath.Join(t.TempDir(), "session", "state.json")
+
+	session, err := NewSessionWithFile(g, path)
+	require.NoError(t, err)
+	commit, err := session.Resolve(context.Background(), remote, pubRepoMainBranch, SessionResolveOpts{})
+	require.NoError(t, err)
+	require.Equal(t, hashes[0], commit.Hash.String())
+
+	// Move the remote branch on.
+	two := commitLocalRepo(t, remote, "README.md", "two")
+
+	// A session loaded from the file is still pinned to the original hash.
+	session, err = NewSessionWithFile(g, path)
+	require.NoError(t, err)
+	commit, err = session.Resolve(context.Background(), remote, pubRepoMainBranch, SessionResolveOpts{})
+	require.NoError(t, err)
+	require.Equal(t, hashes[0], commit.Hash.String())
+	require.Equal(t, SessionState{Entries: []SessionEntry{{remote, pubRepoMainBranch, hashes[0]}}}, session.Snapshot())
+
+	// A new session resolves the latest hash.
+	commit, err = NewSession(g).Resolve(context.Background(), remote, pubRepoMainBranch, SessionResolveOpts{})
+	require.NoError(t, err)
+	require.Equal(t, two, commit.Hash.String())
+}
+
+// Verify that restoring a snapshot reproduces the resolution of the session it was taken from.
+func TestGitSession_Snapshot_Restore(t *testing.T) {
+	remote, hashes := initLocalRepo(t, "one")
+	g := NewWithCache(&AuthOptions{Local: true}, NewPlainFscache(t.TempDir()))
+
+	buf := &bytes.Buffer{}
+	session, err := NewSessionWithReadWriter(g, buf)
+	require.NoError(t, err)
+	err = session.Set(context.Background(), remote, pubRepoMainBranch, SessionSetOpts{})
+	require.NoError(t, err)
+	state := session.Snapshot()
+	require.Equal(t, []SessionEntry{{remote, pubRepoMainBranch, hashes[0]}}, state.Entries)
+
+	two := commitLocalRepo(t, remote, "README.md", "two")
+	session = NewSession(g)
+	commit, err := session.Resolve(context.Background(), remote, pubRepoMainBranch, SessionResolveOpts{})
+	require.NoError(t, err)
+	require.Equal(t, two, commit.Hash.String())
+
+	require.NoError(t, session.Restore(state))
+	commit, err = session.Resolve(context.Background(), remote, pubRepoMainBranch, SessionResolveOpts{})
+	require.NoError(t, err)
+	require.Equal(t, hashes[0], commit.Hash.String())
+
+	// The journal written by the read writer can be reloaded.
+	session, err = NewSessionWithReadWriter(g, bytes.NewBuffer(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, state, session.Snapshot())
+}
@@ -0,0 +1,203 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/anz-bank/golden-retriever/retriever"
+)
+
+// Repositories using git's SHA-256 object format aren't supported by go-git (other than when built for SHA-256 only),
+// so they are retrieved using the git cli instead. This requires a file system cache.
+
+const objectFormatSHA256 = "sha256"
+
+// isSHA256 reports whether the resource is within a repository using the SHA-256 object format, as known before
+// contacting the remote repository.
+func (a Git) isSHA256(resource *retriever.Resource) bool {
+	if resource.Ref != nil && resource.Ref.Hash().IsSHA256() {
+		return true
+	}
+	if r, ok := a.cacher.Get(resource.Repo); ok {
+		return isSHA256Repo(r)
+	}
+	return false
+}
+
+// isSHA256Repo reports whether the repository uses the SHA-256 object format.
+func isSHA256Repo(r *git.Repository) bool {
+	cfg, err := r.Config()
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(cfg.Raw.Section("extensions").Option("objectformat"), objectFormatSHA256)
+}
+
+// errSHA256Advertised is returned when a repository advertises that it uses the SHA-256 object format.
+var errSHA256Advertised = errors.New("repository uses the SHA-256 object format")
+
+// isSHA256AdvertisedErr reports whether the error is the failure of go-git to parse the references advertised by a
+// repository using the SHA-256 object format.
+func isSHA256AdvertisedErr(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "object-format="+objectFormatSHA256)
+}
+
+// retrieveSHA256 retrieves the resource (a copy of that of the caller) from a repository using the SHA-256 object
+// format, setting the hash of the reference of the resource to that of the retrieved commit.
+func (a Git) retrieveSHA256(ctx context.Context, resource *retriever.Resource) (*retriever.Result, error) {
+	c, plain := a.cacher.(PlainFsCache)
+	if !plain {
+		return nil, fmt.Errorf("repository: %v uses the SHA-256 object format, which requires a file system cache", resource.Repo)
+	}
+	if resource.Ref == nil {
+		resource.Ref = retriever.HEADReference()
+	}
+	dir := c.RepoDir(resource.Repo)
+	log.Debugf("retrieving resource: %v from SHA-256 repository: %v", resource, dir)
+
+	var hash string
+	var tag bool
+	err := withAuth0(&a, resource.Repo, func(auth transport.AuthMethod, u string) error {
+		env := cliAuthEnv(auth)
+		fetch := []string{"fetch", "-q", "--depth", "1", u}
+		if _, err := os.Stat(filepath.Join(dir, git.GitDirName)); errors.Is(err, os.ErrNotExist) {
+			if _, err := gitCLI(ctx, "", "init", "-q", "--object-format="+objectFormatSHA256, dir); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+
+		if resource.Ref.IsHash() {
+			hash = resource.Ref.Hash().String()
+			if _, err := gitCLI(ctx, dir, "cat-file", "-e", hash+"^{commit}"); err == nil {
+				return nil
+			}
+			_, err := gitCLIEnv(ctx, dir, env, append(fetch, hash)...)
+			return err
+		}
+
+		if _, err := gitCLIEnv(ctx, dir, env, append(fetch, resource.Ref.Name())...); err != nil {
+			return err
+		}
+		out, err := gitCLI(ctx, dir, "rev-parse", "--verify", "FETCH_HEAD^{commit}")
+		hash = strings.TrimSpace(out)
+		if err != nil {
+			return err
+		}
+
+		// e.g. <hash>\t\ttag 'v1.0.0' of <url>
+		fetched, err := os.ReadFile(filepath.Join(dir, git.GitDirName, "FETCH_HEAD"))
+		if _, desc, ok := strings.Cut(string(fetched), "\t\t"); err == nil && ok {
+			tag = strings.HasPrefix(desc, "tag ")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching reference: %v of SHA-256 repository: %w", resource.Ref, err)
+	}
+
+	h, err := retriever.NewHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	if err := resource.Ref.SetHash(h); err != nil {
+		return nil, err
+	}
+
+	content, err := gitCLI(ctx, dir, "cat-file", "blob", hash+":"+resource.Filepath)
+	if err != nil {
+		return nil, fmt.Errorf("error showing file: %v: %w", resource.Filepath, err)
+	}
+	return a.resultSHA256(ctx, dir, resource, []byte(content), tag)
+}
+
+// resultSHA256 returns the result of retrieving the content of the resource from the SHA-256 repository in the directory,
+// whose reference was fetched as a tag if tag is true.
+func (a Git) resultSHA256(ctx context.Context, dir string, resource *retriever.Resource, content []byte, tag bool) (*retriever.Result, error) {
+	result := retriever.NewResult(resource, content)
+	hash := resource.Ref.Hash().String()
+	result.RefType = refTypeOf(resource.Ref, func(string) bool { return tag })
+
+	// e.g. 100644 blob <hash>\tREADME.md
+	out, err := gitCLI(ctx, dir, "ls-tree", hash, "--", resource.Filepath)
+	if err != nil {
+		return nil, err
+	}
+	if fields := strings.Fields(out); len(fields) >= 3 {
+		if mode, err := filemode.New(fields[0]); err == nil {
+			result.Mode, _ = mode.ToOSFileMode()
+		}
+		if result.Blob, err = retriever.NewHash(fields[2]); err != nil {
+			return nil, err
+		}
+	}
+
+	out, err = gitCLI(ctx, dir, "show", "-s", "--format=%an%x00%ae%x00%at", hash)
+	if err != nil {
+		return nil, err
+	}
+	if author := strings.Split(strings.TrimSpace(out), "\x00"); len(author) == 3 {
+		result.Author, result.Email = author[0], author[1]
+		if at, err := strconv.ParseInt(author[2], 10, 64); err == nil {
+			result.Time = time.Unix(at, 0)
+		}
+	}
+
+	result.Remote, result.Auth = a.servedBy(nil, resource.Repo)
+	return result, nil
+}
+
+// cliAuthEnv returns the environment passing the credentials of the authentication method (if any) to the git cli as
+// configuration (following any already given by GIT_CONFIG_COUNT), so that they aren't visible in its arguments (e.g.
+// to other users via ps). SSH authentication is left to the configuration of the git cli.
+func cliAuthEnv(auth transport.AuthMethod) []string {
+	basic, ok := auth.(*http.BasicAuth)
+	if !ok {
+		return nil
+	}
+	creds := base64.StdEncoding.EncodeToString([]byte(basic.Username + ":" + basic.Password))
+	n, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	return []string{
+		fmt.Sprintf("GIT_CONFIG_COUNT=%d", n+1),
+		fmt.Sprintf("GIT_CONFIG_KEY_%d=http.extraHeader", n),
+		fmt.Sprintf("GIT_CONFIG_VALUE_%d=Authorization: Basic %s", n, creds),
+	}
+}
+
+// gitCLI runs the git cli within the directory, returning its output.
+func gitCLI(ctx context.Context, dir string, args ...string) (string, error) {
+	return gitCLIEnv(ctx, dir, nil, args...)
+}
+
+// gitCLIEnv runs the git cli within the directory with the environment variables added to those of the process,
+// returning its output.
+func gitCLIEnv(ctx context.Context, dir string, env []string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("error running git: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
@@ -0,0 +1,110 @@
+package git
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/anz-bank/golden-retriever/retriever"
+)
+
+// initSHA256Repo returns a local repository using the SHA-256 object format, skipping the test if the git cli doesn't
+// support it.
+func initSHA256Repo(t *testing.T, contents ...string) (string, []string) {
+	dir := t.TempDir()
+	if err := execute(dir, "git", "init", "-q", "-b", pubRepoMainBranch, "--object-format=sha256"); err != nil {
+		t.Skipf("git doesn't support the SHA-256 object format: %v", err)
+	}
+	hashes := make([]string, 0, len(contents))
+	for _, content := range contents {
+		hashes = append(hashes, commitLocalRepo(t, dir, "README.md", content))
+	}
+	return dir, hashes
+}
+
+func TestGitRetrieve_SHA256(t *testing.T) {
+	dir, hashes := initSHA256Repo(t, "v1\n", "v2\n")
+	require.NoError(t, execute(dir, "git", "tag", "v1", hashes[0]))
+	require.Len(t, hashes[1], 64)
+	cacher := NewPlainFscache(t.TempDir())
+	g := NewWithCache(&AuthOptions{Local: true}, cacher)
+
+	resource := &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: retriever.HEADReference()}
+	result, err := g.RetrieveWithInfo(context.Background(), resource)
+	require.NoError(t, err)
+	require.Equal(t, "v2\n", string(result.Content))
+	require.Equal(t, hashes[1], result.Hash.String())
+	require.True(t, result.Hash.IsSHA256())
+	require.Len(t, result.Blob.String(), 64)
+	require.Equal(t, "Tester", result.Author)
+	require.True(t, resource.Ref.Hash().IsZero())
+
+	r, ok := cacher.Get(dir)
+	require.True(t, ok)
+	require.True(t, isSHA256Repo(r))
+
+	resource = &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: retriever.NewSymbolicReference("v1")}
+	result, err = g.RetrieveWithInfo(context.Background(), resource)
+	require.NoError(t, err)
+	require.Equal(t, "v1\n", string(result.Content))
+	require.Equal(t, hashes[0], result.Hash.String())
+	require.Equal(t, retriever.ReferenceTypeTag, result.RefType)
+
+	h, err := retriever.NewHash(hashes[0])
+	require.NoError(t, err)
+	ref, err := retriever.NewHashReference(h)
+	require.NoError(t, err)
+	resource = &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: ref}
+	b, err := NewWithCache(&AuthOptions{Local: true}, NewPlainFscache(t.TempDir())).Retrieve(context.Background(), resource)
+	require.NoError(t, err)
+	require.Equal(t, "v1\n", string(b))
+	require.Equal(t, dir+"/README.md@"+hashes[0], resource.Path())
+}
+
+func TestGitRetrieve_SHA256_Memory(t *testing.T) {
+	dir, _ := initSHA256Repo(t, "v1")
+	g := NewWithCache(&AuthOptions{Local: true}, NewMemcache())
+
+	_, err := g.Retrieve(context.Background(), &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: retriever.HEADReference()})
+	require.ErrorContains(t, err, "requires a file system cache")
+}
+
+func TestGitCLICredentials(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake git cli is a shell script")
+	}
+	bin := t.TempDir()
+	out := filepath.Join(bin, "out")
+	script := "#!/bin/sh\necho \"$@\" > " + out + "\nenv | grep ^GIT_CONFIG_ >> " + out + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "git"), []byte(script), 0755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "core.autocrlf")
+	t.Setenv("GIT_CONFIG_VALUE_0", "false")
+
+	auth := &http.BasicAuth{Username: "user", Password: "s3cret-token"}
+	_, err := lsRemoteCLI(context.Background(), auth, "https://example.com/org/repo")
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(out)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	creds := base64.StdEncoding.EncodeToString([]byte("user:s3cret-token"))
+	require.Equal(t, "ls-remote --symref https://example.com/org/repo", lines[0])
+	require.NotContains(t, lines[0], creds)
+	require.ElementsMatch(t, []string{
+		"GIT_CONFIG_COUNT=2",
+		"GIT_CONFIG_KEY_0=core.autocrlf",
+		"GIT_CONFIG_VALUE_0=false",
+		"GIT_CONFIG_KEY_1=http.extraHeader",
+		"GIT_CONFIG_VALUE_1=Authorization: Basic " + creds,
+	}, lines[1:])
+}
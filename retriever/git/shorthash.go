@@ -0,0 +1,153 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/anz-bank/golden-retriever/retriever"
+)
+
+// shortHashDepths are the depths of the progressively deeper fetches made to find the commit of a short hash, the last
+// of which fetches the entire history (go-git doesn't support --unshallow, see https://git-scm.com/docs/shallow).
+var shortHashDepths = []int{16, 256, 4096, 2147483647}
+
+// AmbiguousHashError is returned when a short hash matches more than one commit.
+type AmbiguousHashError struct {
+	Prefix     string
+	Candidates []string
+}
+
+func (e *AmbiguousHashError) Error() string {
+	return fmt.Sprintf("short hash %s is ambiguous, candidates: %s", e.Prefix, strings.Join(e.Candidates, ", "))
+}
+
+// ResolveShortHash resolves the short hash reference of the resource to the full hash of its commit.
+//
+// The commit is searched for within the repository, then resolved via the RefResolver of the host (if any), and
+// finally within the history of every branch and tag as it is fetched progressively deeper. An AmbiguousHashError is
+// returned if the short hash matches multiple commits.
+func (a Git) ResolveShortHash(ctx context.Context, r *git.Repository, resource *retriever.Resource) error {
+	if resource.Ref.IsHash() {
+		return nil
+	}
+	h, err := a.findShortHash(ctx, r, resource.Repo, resource.Ref.Name())
+	if err != nil {
+		return err
+	}
+	hash, err := retriever.NewHash(h)
+	if err != nil {
+		return err
+	}
+	return resource.Ref.SetHash(hash)
+}
+
+func (a Git) findShortHash(ctx context.Context, r *git.Repository, repo, prefix string) (string, error) {
+	prefix = strings.ToLower(prefix)
+	if h, found, err := matchShortHash(r, prefix); err != nil || found {
+		return h, err
+	}
+
+	if h, err := a.resolveRef(ctx, repo, prefix); err == nil && strings.HasPrefix(h, prefix) {
+		hash, err := retriever.NewHash(h)
+		if err == nil && a.FetchCommitWithOpts(ctx, r, repo, hash, FetchOpts{Depth: 1}) == nil {
+			return h, nil
+		}
+	} else {
+		log.Debugf("short hash: %v not resolved via API: %v", prefix, err)
+	}
+
+	spec := config.RefSpec("+refs/heads/*:refs/remotes/origin/*")
+	known, err := countCommits(r)
+	if err != nil {
+		return "", err
+	}
+	for _, depth := range shortHashDepths {
+		log.Debugf("fetching repo: %v at depth: %v to find short hash: %v", repo, depth, prefix)
+		err := a.FetchRefSpec(ctx, r, repo, spec, FetchOpts{Depth: depth, Force: true, Tags: FetchOptTagsAll})
+		if err != nil {
+			return "", fmt.Errorf("error fetching history to find short hash: %v: %w", prefix, err)
+		}
+		if h, found, err := matchShortHash(r, prefix); err != nil || found {
+			return h, err
+		}
+		n, err := countCommits(r)
+		if err != nil {
+			return "", err
+		}
+		if n == known {
+			break // The entire history has been fetched.
+		}
+		known = n
+	}
+	return "", fmt.Errorf("short hash %s not found", prefix)
+}
+
+// matchShortHash returns the hash of the only commit within the repository beginning with the prefix, whether such a
+// commit was found, or an AmbiguousHashError if there are multiple.
+func matchShortHash(r *git.Repository, prefix string) (string, bool, error) {
+	iter, err := r.CommitObjects()
+	if err != nil {
+		return "", false, err
+	}
+	var matches []string
+	err = iter.ForEach(func(c *object.Commit) error {
+		if h := c.Hash.String(); strings.HasPrefix(h, prefix) {
+			matches = append(matches, h)
+		}
+		return nil
+	})
+	slices.Sort(matches)
+	matches = slices.Compact(matches) // The same object may be stored in multiple packfiles.
+	switch {
+	case err != nil:
+		return "", false, err
+	case len(matches) > 1:
+		return "", false, &AmbiguousHashError{Prefix: prefix, Candidates: matches}
+	case len(matches) == 1:
+		return matches[0], true, nil
+	default:
+		return "", false, nil
+	}
+}
+
+func countCommits(r *git.Repository) (int, error) {
+	iter, err := r.CommitObjects()
+	if err != nil {
+		return 0, err
+	}
+	seen := map[plumbing.Hash]bool{}
+	err = iter.ForEach(func(c *object.Commit) error {
+		seen[c.Hash] = true
+		return nil
+	})
+	return len(seen), err
+}
+
+// isRefName reports whether the short hash is the name of a branch or tag, which (as for git) takes precedence over
+// commits whose hash begins with it, e.g. a branch named 20240101. The refs of the cached repository (if any) are
+// checked first, then those advertised by the remote repository unless a commit of the cached repository matches.
+func (a Git) isRefName(ctx context.Context, r *git.Repository, repo, name string) bool {
+	if r != nil {
+		for _, rule := range []string{"refs/tags/%s", "refs/heads/%s", "refs/remotes/origin/%s"} {
+			if _, err := r.Reference(plumbing.ReferenceName(fmt.Sprintf(rule, name)), false); err == nil {
+				return true
+			}
+		}
+		if _, found, err := matchShortHash(r, strings.ToLower(name)); found || err != nil {
+			return false
+		}
+	}
+	if a.offline {
+		return false
+	}
+	rr, err := a.ResolveRemote(ctx, repo, name)
+	return err == nil && rr.Name != ""
+}
@@ -0,0 +1,125 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anz-bank/golden-retriever/retriever"
+)
+
+func TestGitRetrieve_ShortHash(t *testing.T) {
+	contents := make([]string, 20)
+	for i := range contents {
+		contents[i] = fmt.Sprintf("v%d", i)
+	}
+	dir, hashes := initLocalRepo(t, contents...)
+	g := NewWithCache(&AuthOptions{Local: true}, NewPlainFscache(t.TempDir()))
+
+	// The first commit is beyond the first deepening of the history.
+	ref, err := retriever.NewShortHashReference(hashes[0][:8])
+	require.NoError(t, err)
+	resource := &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: ref}
+	result, err := g.RetrieveWithInfo(context.Background(), resource)
+	require.NoError(t, err)
+	require.Equal(t, "v0", string(result.Content))
+	require.Equal(t, hashes[0], result.Hash.String())
+	require.False(t, resource.Ref.IsHash())
+
+	// The repository is now known.
+	ref, err = retriever.NewShortHashReference(hashes[10][:7])
+	require.NoError(t, err)
+	b, err := g.Retrieve(context.Background(), &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: ref})
+	require.NoError(t, err)
+	require.Equal(t, "v10", string(b))
+
+	ref, err = retriever.NewShortHashReference("0000000")
+	require.NoError(t, err)
+	_, err = g.Retrieve(context.Background(), &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: ref})
+	require.ErrorContains(t, err, "short hash 0000000 not found")
+}
+
+func TestGitRetrieve_HexRefName(t *testing.T) {
+	dir, hashes := initLocalRepo(t, "v1", "v2")
+	require.NoError(t, execute(dir, "git", "branch", "20240101", hashes[0]))
+	require.NoError(t, execute(dir, "git", "tag", "deadbeef", hashes[0]))
+	cache := NewPlainFscache(t.TempDir())
+	ctx := context.Background()
+
+	// Names are resolved via the remote repository before it is cached, then via the cached repository.
+	for _, g := range []*Git{
+		NewWithCache(&AuthOptions{Local: true}, cache),
+		NewWithCache(&AuthOptions{Local: true}, cache),
+		NewWithOptions(&NewGitOptions{AuthOptions: &AuthOptions{Local: true}, Cacher: cache, Offline: true}),
+	} {
+		for _, name := range []string{"20240101", "deadbeef"} {
+			ref, err := retriever.ParseReference(name)
+			require.NoError(t, err)
+			require.True(t, ref.IsShortHash())
+			result, err := g.RetrieveWithInfo(ctx, &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: ref})
+			require.NoError(t, err, name)
+			require.Equal(t, "v1", string(result.Content), name)
+			require.Equal(t, hashes[0], result.Hash.String(), name)
+			require.Equal(t, name, result.Ref, name)
+		}
+	}
+
+	diff, err := NewWithCache(&AuthOptions{Local: true}, cache).Diff(ctx, dir, "20240101", "main", DiffOpts{})
+	require.NoError(t, err)
+	require.Equal(t, hashes[0], diff.From.String())
+}
+
+func TestGitSet_ShortHash(t *testing.T) {
+	dir, hashes := initLocalRepo(t, "v1", "v2", "v3")
+	g := NewWithCache(&AuthOptions{Local: true}, NewPlainFscache(t.TempDir()))
+
+	result, err := g.Set(context.Background(), dir, hashes[0][:8], SetOpts{Depth: 1})
+	require.NoError(t, err)
+	require.Equal(t, hashes[0], result.Commit.Hash.String())
+}
+
+func TestMatchShortHash_Ambiguous(t *testing.T) {
+	r, err := git.Init(memory.NewStorage(), nil)
+	require.NoError(t, err)
+
+	// Create commits until two share a prefix.
+	seen := map[string]string{}
+	var prefix string
+	for i := 0; prefix == ""; i++ {
+		h := storeCommit(t, r, fmt.Sprintf("commit %d", i))
+		p := h[:retriever.MinShortHashLen]
+		if other, ok := seen[p]; ok && other != h {
+			prefix = p
+		}
+		seen[p] = h
+	}
+
+	_, _, err = matchShortHash(r, prefix)
+	var ambiguous *AmbiguousHashError
+	require.ErrorAs(t, err, &ambiguous)
+	require.Equal(t, prefix, ambiguous.Prefix)
+	require.Len(t, ambiguous.Candidates, 2)
+
+	h, found, err := matchShortHash(r, ambiguous.Candidates[0])
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, ambiguous.Candidates[0], h)
+}
+
+// storeCommit stores a commit with the message (and an empty tree) within the repository, returning its hash.
+func storeCommit(t *testing.T, r *git.Repository, msg string) string {
+	sig := object.Signature{Name: "Tester", Email: "email@address.com", When: time.Unix(0, 0).UTC()}
+	c := &object.Commit{Author: sig, Committer: sig, Message: msg, TreeHash: plumbing.ZeroHash}
+	obj := r.Storer.NewEncodedObject()
+	require.NoError(t, c.Encode(obj))
+	h, err := r.Storer.SetEncodedObject(obj)
+	require.NoError(t, err)
+	return h.String()
+}
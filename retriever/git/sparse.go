@@ -0,0 +1,241 @@
+package git
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	log "github.com/sirupsen/logrus"
+)
+
+// sparseCheckoutFile is the file (relative to the git directory) listing the directories of a sparse checkout.
+var sparseCheckoutFile = filepath.Join("info", "sparse-checkout")
+
+// cleanSparse returns the sparse directories in a canonical form: slash separated, without leading or trailing
+// slashes, sorted and without duplicates.
+func cleanSparse(dirs []string) []string {
+	result := make([]string, 0, len(dirs))
+	for _, d := range dirs {
+		d = strings.Trim(path.Clean("/"+filepath.ToSlash(d)), "/")
+		if d == "" {
+			return nil // The root directory includes everything.
+		}
+		result = append(result, d)
+	}
+	slices.Sort(result)
+	return slices.Compact(result)
+}
+
+// inSparse reports whether the file is within any of the sparse directories (all files are within an empty set).
+func inSparse(name string, dirs []string) bool {
+	if len(dirs) == 0 {
+		return true
+	}
+	for _, d := range dirs {
+		if name == d || strings.HasPrefix(name, d+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Sparse returns the directories of the sparse checkout of the repository, or nil if it is fully checked out.
+func (r *Repo) Sparse() ([]string, error) {
+	w, err := r.r.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(w.Filesystem.Root(), git.GitDirName, sparseCheckoutFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var dirs []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			dirs = append(dirs, line)
+		}
+	}
+	return cleanSparse(dirs), scanner.Err()
+}
+
+// setSparse records the directories of the sparse checkout in the same manner as git sparse-checkout (non-cone mode).
+func (r *Repo) setSparse(dotgit string, dirs []string) error {
+	file := filepath.Join(dotgit, sparseCheckoutFile)
+	cfg, err := r.r.Config()
+	if err != nil {
+		return err
+	}
+	if len(dirs) == 0 {
+		cfg.Raw.Section("core").RemoveOption("sparseCheckout")
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return r.r.SetConfig(cfg)
+	}
+
+	var b strings.Builder
+	for _, d := range dirs {
+		b.WriteString("/" + d + "/\n")
+	}
+	if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	cfg.Raw.Section("core").SetOption("sparseCheckout", "true")
+	return r.r.SetConfig(cfg)
+}
+
+// sparseCheckout checks out the commit, only writing the files within the given directories to the worktree. Files
+// outside of the directories are marked as skipped within the index (as git does) and removed from the worktree, so
+// that repeated checkouts correctly widen or narrow the sparse set. An empty set of directories restores a full
+// checkout. Unless forced, only the files that differ from the current index are written.
+func (r *Repo) sparseCheckout(hash plumbing.Hash, dirs []string, force bool) error {
+	log.Debugf("sparse checking out repo: %v to hash: %v with directories: %v", r, hash, dirs)
+	commit, err := r.r.CommitObject(hash)
+	if err != nil {
+		return err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+	w, err := r.r.Worktree()
+	if err != nil {
+		return err
+	}
+	old, err := r.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+	prev := make(map[string]*index.Entry, len(old.Entries))
+	for _, e := range old.Entries {
+		prev[e.Name] = e
+	}
+
+	idx := &index.Index{Version: 2}
+	err = tree.Files().ForEach(func(f *object.File) error {
+		e := &index.Entry{Name: f.Name, Hash: f.Hash, Mode: f.Mode}
+		p, known := prev[f.Name]
+		delete(prev, f.Name)
+
+		if !inSparse(f.Name, dirs) {
+			e.SkipWorktree = true
+			idx.Version = 3 // Required for extended flags.
+			if known && !p.SkipWorktree {
+				if err := removeFile(w.Filesystem, f.Name); err != nil {
+					return err
+				}
+			}
+			idx.Entries = append(idx.Entries, e)
+			return nil
+		}
+
+		_, statErr := w.Filesystem.Lstat(f.Name)
+		if force || !known || p.SkipWorktree || p.Hash != f.Hash || p.Mode != f.Mode || statErr != nil {
+			if err := writeFile(w.Filesystem, f); err != nil {
+				return fmt.Errorf("error writing file: %v: %w", f.Name, err)
+			}
+		}
+		if fi, err := w.Filesystem.Lstat(f.Name); err == nil {
+			e.Size = uint32(fi.Size())
+			e.ModifiedAt = fi.ModTime()
+		}
+		idx.Entries = append(idx.Entries, e)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Remove the files that are no longer part of the tree.
+	for name, e := range prev {
+		if !e.SkipWorktree {
+			if err := removeFile(w.Filesystem, name); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := r.r.Storer.SetIndex(idx); err != nil {
+		return err
+	}
+	if err := r.r.Storer.SetReference(plumbing.NewHashReference(plumbing.HEAD, hash)); err != nil {
+		return err
+	}
+	return r.setSparse(filepath.Join(w.Filesystem.Root(), git.GitDirName), dirs)
+}
+
+// writeFile writes the content of the git file to the worktree filesystem.
+func writeFile(fs billy.Filesystem, f *object.File) (err error) {
+	if err := fs.MkdirAll(path.Dir(f.Name), os.ModePerm); err != nil {
+		return err
+	}
+	if err := fs.Remove(f.Name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	from, err := f.Reader()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = from.Close() }()
+
+	if f.Mode == filemode.Symlink {
+		target, err := io.ReadAll(from)
+		if err != nil {
+			return err
+		}
+		return fs.Symlink(string(target), f.Name)
+	}
+
+	mode, err := f.Mode.ToOSFileMode()
+	if err != nil {
+		return err
+	}
+	to, err := fs.OpenFile(f.Name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := to.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	_, err = io.Copy(to, from)
+	return err
+}
+
+// removeFile removes the file from the worktree filesystem, along with any parent directories left empty.
+func removeFile(fs billy.Filesystem, name string) error {
+	if err := fs.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for dir := path.Dir(name); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		entries, err := fs.ReadDir(dir)
+		if err != nil || len(entries) > 0 {
+			break
+		}
+		if err := fs.Remove(dir); err != nil {
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Verify that a sparse checkout only writes the requested directories and can be widened and narrowed.
+func TestGitSet_Sparse(t *testing.T) {
+	remote, _ := initLocalRepo(t, "readme")
+	commitLocalRepo(t, remote, "api/spec.yaml", "api v1")
+	commitLocalRepo(t, remote, "docs/guide/index.md", "docs v1")
+	cacher := NewPlainFscache(t.TempDir())
+	g := NewWithCache(&AuthOptions{Local: true}, cacher)
+	repoDir := cacher.RepoDir(remote)
+
+	requireFiles := func(t *testing.T, dir string, files map[string]string) {
+		for _, name := range []string{"README.md", "api/spec.yaml", "docs/guide/index.md"} {
+			if content, ok := files[name]; ok {
+				require.FileExists(t, filepath.Join(dir, name))
+				b, err := os.ReadFile(filepath.Join(dir, name))
+				require.NoError(t, err)
+				require.Equal(t, content, string(b))
+			} else {
+				require.NoFileExists(t, filepath.Join(dir, name))
+			}
+		}
+		status, err := exec.Command("git", "-C", dir, "status", "--porcelain").Output()
+		require.NoError(t, err)
+		require.Empty(t, string(status))
+	}
+
+	_, err := g.Set(context.Background(), remote, pubRepoMainBranch, SetOpts{Sparse: []string{"api"}})
+	require.NoError(t, err)
+	requireFiles(t, repoDir, map[string]string{"api/spec.yaml": "api v1"})
+	require.NoDirExists(t, filepath.Join(repoDir, "docs"))
+
+	// Widen the sparse set.
+	_, err = g.Set(context.Background(), remote, pubRepoMainBranch, SetOpts{Sparse: []string{"api", "docs/guide/"}})
+	require.NoError(t, err)
+	requireFiles(t, repoDir, map[string]string{"api/spec.yaml": "api v1", "docs/guide/index.md": "docs v1"})
+
+	// Narrow the sparse set.
+	_, err = g.Set(context.Background(), remote, pubRepoMainBranch, SetOpts{Sparse: []string{"docs"}})
+	require.NoError(t, err)
+	requireFiles(t, repoDir, map[string]string{"docs/guide/index.md": "docs v1"})
+	require.NoDirExists(t, filepath.Join(repoDir, "api"))
+
+	// Update the sparse set to a new commit.
+	commitLocalRepo(t, remote, "api/spec.yaml", "api v2")
+	commitLocalRepo(t, remote, "docs/guide/index.md", "docs v2")
+	_, err = g.Set(context.Background(), remote, pubRepoMainBranch, SetOpts{Sparse: []string{"docs"}})
+	require.NoError(t, err)
+	requireFiles(t, repoDir, map[string]string{"docs/guide/index.md": "docs v2"})
+
+	// Restore the full checkout.
+	_, err = g.Set(context.Background(), remote, pubRepoMainBranch, SetOpts{})
+	require.NoError(t, err)
+	requireFiles(t, repoDir, map[string]string{"README.md": "readme", "api/spec.yaml": "api v2", "docs/guide/index.md": "docs v2"})
+
+	// Sparsely check out a worktree.
+	result, err := g.Set(context.Background(), remote, pubRepoMainBranch, SetOpts{Worktree: true, Sparse: []string{"api"}})
+	require.NoError(t, err)
+	requireFiles(t, result.Dir, map[string]string{"api/spec.yaml": "api v2"})
+}
+
+// Verify that a session sparsely checks out a repository.
+func TestGitSession_Set_Sparse(t *testing.T) {
+	remote, _ := initLocalRepo(t, "readme")
+	commitLocalRepo(t, remote, "api/spec.yaml", "api v1")
+	cacher := NewPlainFscache(t.TempDir())
+	g := NewWithCache(&AuthOptions{Local: true}, cacher)
+
+	err := NewSession(g).Set(context.Background(), remote, pubRepoMainBranch, SessionSetOpts{Sparse: []string{"api"}})
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(cacher.RepoDir(remote), "api", "spec.yaml"))
+	require.NoFileExists(t, filepath.Join(cacher.RepoDir(remote), "README.md"))
+}
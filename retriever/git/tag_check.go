@@ -0,0 +1,69 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/anz-bank/golden-retriever/retriever"
+)
+
+var _ retriever.TagResolver = Git{}
+
+// ResolveRemoteTag resolves the commit the tag of the remote repository refers to (with annotated tags peeled) using
+// the references advertised by the remote repository, returning false if it has no such tag.
+func (a Git) ResolveRemoteTag(ctx context.Context, repo, tag string) (retriever.Hash, bool, error) {
+	rr, err := a.ResolveRemote(ctx, repo, "refs/tags/"+strings.TrimPrefix(tag, "refs/tags/"))
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return retriever.ZeroHash, false, nil
+	} else if err != nil {
+		return retriever.ZeroHash, false, err
+	}
+	return rr.Hash, true, nil
+}
+
+// checkTag checks the tag (resolved within the cached repository) against the remote repository as per the tag policy
+// (once per tag, unless it fails), returning the tag resolved to the commit to retrieve. Moved tags are fetched again
+// if they are accepted.
+func (a Git) checkTag(ctx context.Context, r *git.Repository, repo string, ref *retriever.Reference) (*retriever.Reference, error) {
+	if a.tagPolicy == retriever.TagPolicyTrust {
+		return ref, nil
+	}
+	key := repo + ":" + ref.Name()
+	if _, checked := a.checkedTags.Load(key); checked {
+		return ref, nil
+	}
+
+	name := strings.TrimPrefix(ref.Name(), "refs/tags/")
+	h, ok, err := a.ResolveRemoteTag(ctx, repo, name)
+	if err != nil {
+		return nil, fmt.Errorf("error checking tag: %v of repo: %v: %w", name, repo, err)
+	}
+	if ok && h != ref.Hash() {
+		moved := &retriever.TagMovedError{Repo: repo, Tag: name, Old: ref.Hash(), New: h}
+		switch a.tagPolicy {
+		case retriever.TagPolicyWarn:
+			log.Warnf("%s, retrieving %s", moved, moved.Old)
+		case retriever.TagPolicyFail:
+			return nil, moved
+		case retriever.TagPolicyAccept:
+			log.Infof("%s, retrieving %s", moved, moved.New)
+			spec := config.RefSpec(fmt.Sprintf("+refs/tags/%s:refs/tags/%[1]s", name))
+			if err := a.FetchRefSpec(ctx, r, repo, spec, FetchOpts{Depth: 1, Force: true, Tags: FetchOptTagsNone}); err != nil {
+				return nil, fmt.Errorf("error fetching moved tag: %v of repo: %v: %w", name, repo, err)
+			}
+			ref = ref.Clone()
+			if err := ref.SetHash(h); err != nil {
+				return nil, err
+			}
+		}
+	}
+	a.checkedTags.Store(key, true)
+	return ref, nil
+}
@@ -0,0 +1,59 @@
+package git
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/anz-bank/golden-retriever/retriever"
+)
+
+func TestGitTagPolicy(t *testing.T) {
+	dir, hashes := initLocalRepo(t, "v1", "v2")
+	require.NoError(t, execute(dir, "git", "tag", "v1.0.0", hashes[0]))
+	cache := NewPlainFscache(t.TempDir())
+	ctx := context.Background()
+	tag := &retriever.Resource{Repo: dir, Filepath: "README.md", Ref: retriever.NewSymbolicReference("v1.0.0")}
+	retrieve := func(policy retriever.TagPolicy, noForcedFetch bool) (*retriever.Result, error) {
+		g := NewWithOptions(&NewGitOptions{AuthOptions: &AuthOptions{Local: true}, Cacher: cache, NoForcedFetch: noForcedFetch, TagPolicy: policy})
+		return g.RetrieveWithInfo(ctx, tag)
+	}
+
+	result, err := retrieve(retriever.TagPolicyFail, false)
+	require.NoError(t, err)
+	require.Equal(t, hashes[0], result.Hash.String())
+
+	h, ok, err := NewWithCache(&AuthOptions{Local: true}, cache).ResolveRemoteTag(ctx, dir, "v1.0.0")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, hashes[0], h.String())
+	_, ok, err = NewWithCache(&AuthOptions{Local: true}, cache).ResolveRemoteTag(ctx, dir, "main")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// The cached commit of a moved tag is retrieved unless the move is accepted.
+	require.NoError(t, execute(dir, "git", "tag", "-f", "v1.0.0", hashes[1]))
+	for _, policy := range []retriever.TagPolicy{retriever.TagPolicyTrust, retriever.TagPolicyWarn} {
+		result, err = retrieve(policy, false)
+		require.NoError(t, err, policy.String())
+		require.Equal(t, hashes[0], result.Hash.String(), policy.String())
+	}
+	for _, noForcedFetch := range []bool{false, true} {
+		_, err = retrieve(retriever.TagPolicyFail, noForcedFetch)
+		var moved *retriever.TagMovedError
+		require.ErrorAs(t, err, &moved)
+		require.Equal(t, dir, moved.Repo)
+		require.Equal(t, "v1.0.0", moved.Tag)
+		require.Equal(t, hashes[0], moved.Old.String())
+		require.Equal(t, hashes[1], moved.New.String())
+	}
+
+	result, err = retrieve(retriever.TagPolicyAccept, false)
+	require.NoError(t, err)
+	require.Equal(t, hashes[1], result.Hash.String())
+	require.Equal(t, "v2", string(result.Content))
+	result, err = retrieve(retriever.TagPolicyTrust, false)
+	require.NoError(t, err)
+	require.Equal(t, hashes[1], result.Hash.String())
+}
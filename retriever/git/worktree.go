@@ -0,0 +1,272 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	log "github.com/sirupsen/logrus"
+)
+
+// worktreesDir is the directory within a PlainFsCache in which worktrees are checked out.
+const worktreesDir = ".worktrees"
+
+// worktreeRefsDir is the directory within the git directory of a worktree holding a file for each Git referencing the
+// worktree, containing the pid of its process, so that worktrees in use by other Gits (or processes) sharing the cache
+// aren't removed.
+const worktreeRefsDir = "golden-retriever-refs"
+
+// worktreesCount numbers the worktrees of each Git within the process.
+var worktreesCount atomic.Int64
+
+// WorktreeDir returns the directory of the worktree of the repository checked out at the given commit hash.
+func (s PlainFsCache) WorktreeDir(repo, hash string) string {
+	return filepath.Join(s.dir, worktreesDir, cleanForSubPath(repo), hash)
+}
+
+// worktrees counts the references to the worktrees checked out by Git.Set, holding a reference file within each
+// referenced worktree.
+type worktrees struct {
+	mutex *sync.Mutex
+	id    string         // The name of the reference files, unique to the Git (and process).
+	refs  map[string]int // The mapping of worktree directories to their number of references
+}
+
+func newWorktrees() *worktrees {
+	id := fmt.Sprintf("%d-%d", os.Getpid(), worktreesCount.Add(1))
+	return &worktrees{mutex: &sync.Mutex{}, id: id, refs: make(map[string]int)}
+}
+
+// refFile returns the reference file of the worktree.
+func (w *worktrees) refFile(dir string) string {
+	return filepath.Join(dir, git.GitDirName, worktreeRefsDir, w.id)
+}
+
+func (w *worktrees) acquire(dir string) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.refs[dir] == 0 {
+		f := w.refFile(dir)
+		if err := os.MkdirAll(filepath.Dir(f), os.ModePerm); err != nil {
+			return err
+		}
+		if err := os.WriteFile(f, []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+			return err
+		}
+	}
+	w.refs[dir]++
+	return nil
+}
+
+// release returns whether the worktree is no longer referenced (by the Git).
+func (w *worktrees) release(dir string) (bool, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	n, ok := w.refs[dir]
+	if !ok {
+		return false, fmt.Errorf("worktree: %v is not referenced", dir)
+	}
+	if n > 1 {
+		w.refs[dir] = n - 1
+		return false, nil
+	}
+	delete(w.refs, dir)
+	if err := os.Remove(w.refFile(dir)); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	return true, nil
+}
+
+func (w *worktrees) referenced(dir string) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.refs[dir] > 0
+}
+
+// worktreeInUse reports whether the worktree has a reference file of a running process, i.e. is referenced by any Git
+// sharing the cache. Reference files left behind by processes that have exited are ignored.
+func worktreeInUse(dir string) (bool, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, git.GitDirName, worktreeRefsDir))
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	for _, e := range entries {
+		b, err := os.ReadFile(filepath.Join(dir, git.GitDirName, worktreeRefsDir, e.Name()))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return false, err
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+		if err != nil || processRunning(pid) {
+			return true, nil
+		}
+		log.Debugf("ignoring reference: %v to worktree: %v of exited process: %d", e.Name(), dir, pid)
+	}
+	return false, nil
+}
+
+// processRunning reports whether the process is (or may be) running.
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return !errors.Is(p.Signal(syscall.Signal(0)), os.ErrProcessDone)
+}
+
+// setWorktree resolves the reference within the repository and checks it out into the worktree of the commit,
+// sharing the object store of the repository.
+func (a Git) setWorktree(ctx context.Context, repo, ref string, opts SetOpts) (*SetResult, error) {
+	c, plain := a.cacher.(PlainFsCache)
+	if !plain {
+		return nil, fmt.Errorf("repository must be a plain repository")
+	}
+
+	resolveOpts := opts
+	resolveOpts.Worktree = false
+	resolveOpts.Checkout = OptCheckoutFalse
+	resolveOpts.Verify = false
+	result, err := a.Set(ctx, repo, ref, resolveOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := result.Commit.Hash
+	dir := c.WorktreeDir(repo, hash.String())
+	a.once.Wait(dir)
+	defer a.once.Unregister(dir)
+
+	rr, created, err := openWorktree(c.RepoDir(repo), dir)
+	if err != nil {
+		return nil, fmt.Errorf("error opening worktree: %v: %w", dir, err)
+	}
+	r := &Repo{g: &a, r: rr, repo: repo}
+	sparse, err := r.Sparse()
+	if err != nil {
+		return nil, fmt.Errorf("error reading sparse checkout: %w", err)
+	}
+	if created || opts.Reset == OptResetTrue || !slices.Equal(sparse, cleanSparse(opts.Sparse)) {
+		log.Debugf("checking out worktree: %v of repo: %v to reference: %v", dir, repo, ref)
+		if err := r.Checkout(hash.String(), CheckoutOpts{Force: true, Sparse: opts.Sparse}); err != nil {
+			return nil, fmt.Errorf("error checking out worktree: %v to reference: %v: %w", dir, ref, err)
+		}
+	} else {
+		log.Debugf("taking no action, worktree: %v of repo: %v already set to reference: %v", dir, repo, ref)
+	}
+
+	if err := a.worktrees.acquire(dir); err != nil {
+		return nil, fmt.Errorf("error referencing worktree: %v: %w", dir, err)
+	}
+	return &SetResult{Commit: result.Commit, Dir: dir}, nil
+}
+
+// openWorktree opens the worktree in the directory, initialising it with the objects of the repository in repoDir as
+// alternates if it doesn't exist.
+func openWorktree(repoDir, dir string) (r *git.Repository, created bool, err error) {
+	dotgit := filepath.Join(dir, git.GitDirName)
+	root := filepath.VolumeName(dotgit) + string(filepath.Separator)
+	st := filesystem.NewStorageWithOptions(osfs.New(dotgit), cache.NewObjectLRUDefault(),
+		filesystem.Options{AlternatesFS: osfs.New(root)})
+
+	r, err = git.Open(st, osfs.New(dir))
+	if err == nil {
+		return r, false, nil
+	} else if err != git.ErrRepositoryNotExists {
+		return nil, false, err
+	}
+
+	r, err = git.Init(st, osfs.New(dir))
+	if err != nil {
+		return nil, false, err
+	}
+	objects, err := filepath.Abs(filepath.Join(repoDir, git.GitDirName, "objects"))
+	if err != nil {
+		return nil, false, err
+	}
+	alternates := filepath.Join(dotgit, "objects", "info", "alternates")
+	if err := os.MkdirAll(filepath.Dir(alternates), os.ModePerm); err != nil {
+		return nil, false, err
+	}
+	if err := os.WriteFile(alternates, []byte(objects+"\n"), 0644); err != nil {
+		return nil, false, err
+	}
+	return r, true, nil
+}
+
+// ReleaseWorktree releases a reference to a worktree returned by Set, removing the worktree once it is no longer
+// referenced (by any Git sharing the cache).
+func (a Git) ReleaseWorktree(dir string) error {
+	a.once.Wait(dir)
+	defer a.once.Unregister(dir)
+
+	unreferenced, err := a.worktrees.release(dir)
+	if err != nil || !unreferenced {
+		return err
+	}
+	return removeWorktree(dir)
+}
+
+// removeWorktree removes the worktree unless it is in use by another Git.
+func removeWorktree(dir string) error {
+	inUse, err := worktreeInUse(dir)
+	if err != nil {
+		return fmt.Errorf("error reading references to worktree: %v: %w", dir, err)
+	}
+	if inUse {
+		log.Debugf("keeping worktree: %v referenced by another Git", dir)
+		return nil
+	}
+	log.Debugf("removing unreferenced worktree: %v", dir)
+	return os.RemoveAll(dir)
+}
+
+// PruneWorktrees removes the worktrees of the repository that are not referenced by any Git sharing the cache, e.g.
+// those left behind by earlier processes.
+func (a Git) PruneWorktrees(repo string) error {
+	c, plain := a.cacher.(PlainFsCache)
+	if !plain {
+		return fmt.Errorf("repository must be a plain repository")
+	}
+	parent := filepath.Dir(c.WorktreeDir(repo, plumbing.ZeroHash.String()))
+	entries, err := os.ReadDir(parent)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if err := a.pruneWorktree(filepath.Join(parent, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a Git) pruneWorktree(dir string) error {
+	a.once.Wait(dir)
+	defer a.once.Unregister(dir)
+
+	if a.worktrees.referenced(dir) {
+		return nil
+	}
+	return removeWorktree(dir)
+}
@@ -0,0 +1,95 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Verify that different references of a repository are checked out into their own worktrees.
+func TestGitSet_Worktree(t *testing.T) {
+	remote, hashes := initLocalRepo(t, "one", "two")
+	require.NoError(t, execute(remote, "git", "tag", "v1", hashes[0]))
+	cacher := NewPlainFscache(t.TempDir())
+	g := NewWithCache(&AuthOptions{Local: true}, cacher)
+
+	main, err := g.Set(context.Background(), remote, pubRepoMainBranch, SetOpts{Worktree: true})
+	require.NoError(t, err)
+	require.Equal(t, hashes[1], main.Commit.Hash.String())
+	require.Equal(t, cacher.WorktreeDir(remote, hashes[1]), main.Dir)
+
+	v1, err := g.Set(context.Background(), remote, "v1", SetOpts{Worktree: true})
+	require.NoError(t, err)
+	require.Equal(t, cacher.WorktreeDir(remote, hashes[0]), v1.Dir)
+
+	// Both worktrees have the content of their reference.
+	file, err := os.ReadFile(filepath.Join(main.Dir, "README.md"))
+	require.NoError(t, err)
+	require.Equal(t, "two", string(file))
+	file, err = os.ReadFile(filepath.Join(v1.Dir, "README.md"))
+	require.NoError(t, err)
+	require.Equal(t, "one", string(file))
+
+	// The repository directory itself is not checked out.
+	_, err = os.Stat(filepath.Join(cacher.RepoDir(remote), "README.md"))
+	require.True(t, os.IsNotExist(err))
+
+	// Requests for the same commit share the worktree, which is removed once it is no longer referenced.
+	hash, err := g.Set(context.Background(), remote, hashes[1], SetOpts{Worktree: true})
+	require.NoError(t, err)
+	require.Equal(t, main.Dir, hash.Dir)
+	require.NoError(t, g.ReleaseWorktree(main.Dir))
+	require.DirExists(t, main.Dir)
+	require.NoError(t, g.ReleaseWorktree(hash.Dir))
+	require.NoDirExists(t, main.Dir)
+	require.Error(t, g.ReleaseWorktree(main.Dir))
+
+	// Worktrees in use by other Gits (or processes) are neither pruned nor removed when released.
+	other := NewWithCache(&AuthOptions{Local: true}, cacher)
+	require.NoError(t, other.PruneWorktrees(remote))
+	require.DirExists(t, v1.Dir)
+	shared, err := other.Set(context.Background(), remote, "v1", SetOpts{Worktree: true})
+	require.NoError(t, err)
+	require.Equal(t, v1.Dir, shared.Dir)
+	require.NoError(t, g.ReleaseWorktree(v1.Dir))
+	require.DirExists(t, v1.Dir)
+	require.NoError(t, other.ReleaseWorktree(shared.Dir))
+	require.NoDirExists(t, v1.Dir)
+
+	// Worktrees left behind by exited processes are pruned.
+	v1, err = g.Set(context.Background(), remote, "v1", SetOpts{Worktree: true})
+	require.NoError(t, err)
+	exited := exec.Command("git", "--version")
+	require.NoError(t, exited.Run())
+	require.NoError(t, os.WriteFile(g.worktrees.refFile(v1.Dir), []byte(strconv.Itoa(exited.Process.Pid)), 0644))
+	require.NoError(t, other.PruneWorktrees(remote))
+	require.NoDirExists(t, v1.Dir)
+}
+
+// Verify that a worktree is reset when requested.
+func TestGitSet_Worktree_Reset(t *testing.T) {
+	remote, _ := initLocalRepo(t, "one")
+	g := NewWithCache(&AuthOptions{Local: true}, NewPlainFscache(t.TempDir()))
+
+	result, err := g.Set(context.Background(), remote, pubRepoMainBranch, SetOpts{Worktree: true})
+	require.NoError(t, err)
+	readme := filepath.Join(result.Dir, "README.md")
+	require.NoError(t, os.WriteFile(readme, []byte("modified"), 0666))
+
+	_, err = g.Set(context.Background(), remote, pubRepoMainBranch, SetOpts{Worktree: true, Reset: OptResetOnCheckout})
+	require.NoError(t, err)
+	file, err := os.ReadFile(readme)
+	require.NoError(t, err)
+	require.Equal(t, "modified", string(file))
+
+	_, err = g.Set(context.Background(), remote, pubRepoMainBranch, SetOpts{Worktree: true, Reset: OptResetTrue})
+	require.NoError(t, err)
+	file, err = os.ReadFile(readme)
+	require.NoError(t, err)
+	require.Equal(t, "one", string(file))
+}
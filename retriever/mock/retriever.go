@@ -10,31 +10,52 @@ import (
 // Retriever is a mock implementation of the Retriever interface.
 type Retriever struct{}
 
+var _ retriever.InfoRetriever = Retriever{}
+
 func (r Retriever) Retrieve(ctx context.Context, resource *retriever.Resource) (content []byte, err error) {
+	result, err := r.RetrieveWithInfo(ctx, resource)
+	if err != nil {
+		return nil, err
+	}
+	return result.Content, nil
+}
+
+// RetrieveWithInfo returns the mock content of the resource with information about its (mock) reference. The resource
+// isn't modified.
+func (r Retriever) RetrieveWithInfo(ctx context.Context, resource *retriever.Resource) (*retriever.Result, error) {
+	resource = resource.Clone()
+	var content []byte
+	refType := retriever.ReferenceTypeBranch
 	switch {
 	case resource.Ref == nil:
 		resource.Ref = retriever.HEADReference()
 		fallthrough
 	case resource.Ref.Name() == retriever.HEAD:
 		resource.Ref.SetName("master")
-		if err = resource.Ref.SetHash(r.HEADHash()); err != nil {
+		if err := resource.Ref.SetHash(r.HEADHash()); err != nil {
 			return nil, err
 		}
-		return r.HEADContent(), nil
+		content = r.HEADContent()
 	case resource.Ref.IsHash():
-		return r.HashContent(), nil
+		refType = retriever.ReferenceTypeHash
+		content = r.HashContent()
 	case resource.Ref.Name() == "master":
-		if err = resource.Ref.SetHash(r.BranchHash()); err != nil {
+		if err := resource.Ref.SetHash(r.BranchHash()); err != nil {
 			return nil, err
 		}
-		return r.BranchContent(), nil
+		content = r.BranchContent()
 	case resource.Ref.Name() == "v1":
-		if err = resource.Ref.SetHash(r.TagHash()); err != nil {
+		if err := resource.Ref.SetHash(r.TagHash()); err != nil {
 			return nil, err
 		}
-		return r.TagContent(), nil
+		refType = retriever.ReferenceTypeTag
+		content = r.TagContent()
+	default:
+		return nil, errors.New("Unknown case")
 	}
-	return nil, errors.New("Unknown case")
+	result := retriever.NewResult(resource, content)
+	result.RefType = refType
+	return result, nil
 }
 
 func (Retriever) HashContent() []byte {
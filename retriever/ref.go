@@ -3,6 +3,7 @@ package retriever
 import (
 	"fmt"
 	"regexp"
+	"strings"
 
 	"github.com/go-git/go-git/v5/plumbing"
 )
@@ -24,6 +25,7 @@ const (
 	ReferenceTypeBranch
 	ReferenceTypeTag
 	ReferenceTypeHash
+	ReferenceTypeShortHash // abbreviated commit hash, e.g. 1e7c4cec
 )
 
 // NewBranchReference returns a new git reference to a branch.
@@ -50,6 +52,15 @@ func NewHashReference(h Hash) (*Reference, error) {
 	return &Reference{hash: h, typ: ReferenceTypeHash}, nil
 }
 
+// NewShortHashReference returns a new reference to the commit whose hash begins with the given prefix (e.g. 1e7c4cec).
+// The full hash is set once the reference has been resolved.
+func NewShortHashReference(prefix string) (*Reference, error) {
+	if !isShortHash(prefix) {
+		return nil, fmt.Errorf("Invalid short commit SHA %s", prefix)
+	}
+	return &Reference{name: strings.ToLower(prefix), typ: ReferenceTypeShortHash}, nil
+}
+
 // HEADReference returns a HEAD git reference.
 func HEADReference() *Reference {
 	return &Reference{name: HEAD, typ: ReferenceTypeBranch}
@@ -75,6 +86,15 @@ func NewReference(n string, h Hash) (*Reference, error) {
 	}
 }
 
+// Clone returns a copy of the reference.
+func (r *Reference) Clone() *Reference {
+	if r == nil {
+		return nil
+	}
+	c := *r
+	return &c
+}
+
 // Name returns the value of the reference name.
 func (r *Reference) Name() string {
 	return r.name
@@ -99,6 +119,25 @@ func (r *Reference) SetHash(hash Hash) error {
 	return nil
 }
 
+func (t ReferenceType) String() string {
+	switch t {
+	case ReferenceTypeUnknown:
+		return "unknown"
+	case ReferenceTypeSymbolic:
+		return "symbolic"
+	case ReferenceTypeBranch:
+		return "branch"
+	case ReferenceTypeTag:
+		return "tag"
+	case ReferenceTypeHash:
+		return "hash"
+	case ReferenceTypeShortHash:
+		return "short-hash"
+	default:
+		return "-"
+	}
+}
+
 func (r *Reference) Type() ReferenceType {
 	return r.typ
 }
@@ -117,6 +156,11 @@ func (r *Reference) IsEmpty() bool {
 	return r == &Reference{}
 }
 
+// IsShortHash reports whether the reference is a short hash reference (which may or may not be resolved).
+func (r *Reference) IsShortHash() bool {
+	return r.typ == ReferenceTypeShortHash
+}
+
 // IsHash reports whether the reference is a HashReference.
 func (r *Reference) IsHash() bool {
 	return !r.hash.IsZero()
@@ -130,10 +174,17 @@ func (r *Reference) String() string {
 	return r.name
 }
 
-type Hash [40]byte
+// Hash is the hexadecimal identity of a git commit, using either the SHA-1 (40 characters) or the SHA-256 (64
+// characters) object format.
+type Hash [64]byte
 
 var ZeroHash Hash
 
+const (
+	sha1HexSize   = 40
+	sha256HexSize = 64
+)
+
 // NewHash returns a new Hash.
 func NewHash(s string) (Hash, error) {
 	if !isHash(s) {
@@ -154,6 +205,9 @@ func (h Hash) String() string {
 	if h.IsZero() {
 		return ""
 	}
+	if h[sha1HexSize] == 0 {
+		return string(h[:sha1HexSize])
+	}
 	return string(h[:])
 }
 
@@ -162,9 +216,23 @@ func (h Hash) IsValid() bool {
 	return isHash(h.String())
 }
 
+// IsSHA256 reports whether the Hash uses the SHA-256 object format.
+func (h Hash) IsSHA256() bool {
+	return h[sha1HexSize] != 0
+}
+
+var hashRegexp = regexp.MustCompile(`^([a-fA-F0-9]{40}|[a-fA-F0-9]{64})$`)
+
 func isHash(str string) bool {
-	if len(str) == 40 {
-		if e, err := regexp.MatchString(`[a-fA-F0-9]{40}`, str); err == nil {
+	return hashRegexp.MatchString(str)
+}
+
+// MinShortHashLen is the minimum length of a short hash, as for git.
+const MinShortHashLen = 4
+
+func isShortHash(str string) bool {
+	if len(str) >= MinShortHashLen && len(str) < sha1HexSize {
+		if e, err := regexp.MatchString(`^[a-fA-F0-9]+$`, str); err == nil {
 			return e
 		}
 	}
@@ -36,3 +36,56 @@ func TestReference(t *testing.T) {
 	}
 
 }
+
+func TestShortHashReference(t *testing.T) {
+	ref, err := NewShortHashReference("1E7C4CEC")
+	require.NoError(t, err)
+	require.True(t, ref.IsShortHash())
+	require.False(t, ref.IsHash())
+	require.Equal(t, "1e7c4cec", ref.Name())
+	require.Equal(t, "1e7c4cec", ref.String())
+
+	h, err := NewHash("1e7c4cecaaa8f76e3c668cebc411f1b03171501f")
+	require.NoError(t, err)
+	require.NoError(t, ref.SetHash(h))
+	require.True(t, ref.IsHash())
+	require.Equal(t, h.String(), ref.String())
+
+	for _, invalid := range []string{"", "1e7", "main", "1e7c4cecaaa8f76e3c668cebc411f1b03171501f"} {
+		_, err := NewShortHashReference(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestParseResource_ShortHash(t *testing.T) {
+	const re = `^(?P<repo>[^/@]+/[^/@]+/[^/@]+)(?P<path>/[^@]*)@?(?P<ref>.*)$`
+	shortHash, err := NewShortHashReference("1e7c4ce")
+	require.NoError(t, err)
+
+	for str, ref := range map[string]*Reference{
+		"github.com/foo/bar/file@1e7c4ce": shortHash,
+		"github.com/foo/bar/file@beef":    NewSymbolicReference("beef"),
+	} {
+		resource, err := ParseResource(str, re, 1, 2, 3)
+		require.NoError(t, err)
+		require.Equal(t, ref, resource.Ref, str)
+	}
+}
+
+func TestHash(t *testing.T) {
+	for _, s := range []string{
+		"1e7c4cecaaa8f76e3c668cebc411f1b03171501f",
+		"ec85029a240bf6078595f69ca2f33df13e7cd238b0ec0b80aaed9f9b50988b2e",
+	} {
+		h, err := NewHash(s)
+		require.NoError(t, err)
+		require.Equal(t, s, h.String())
+		require.True(t, h.IsValid())
+		require.Equal(t, len(s) == 64, h.IsSHA256())
+	}
+
+	for _, s := range []string{"", "1e7c4cec", "1e7c4cecaaa8f76e3c668cebc411f1b03171501fa", "zz7c4cecaaa8f76e3c668cebc411f1b03171501f"} {
+		_, err := NewHash(s)
+		require.Error(t, err, s)
+	}
+}
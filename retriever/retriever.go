@@ -2,9 +2,13 @@ package retriever
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"path"
 	"regexp"
 	"strings"
+	"time"
 )
 
 // Retriever is the interface that wraps the Retrieve method.
@@ -14,11 +18,101 @@ type Retriever interface {
 	Retrieve(ctx context.Context, resource *Resource) (content []byte, err error)
 }
 
+// ErrOffline is returned (wrapped) by retrievers that can't contact remote repositories (i.e. are offline) when
+// retrieving content that isn't available locally.
+var ErrOffline = errors.New("offline")
+
+// InfoRetriever is the interface that wraps the RetrieveWithInfo method.
+// RetrieveWithInfo fetches remote resource and returns its content along with information about where it came from.
+type InfoRetriever interface {
+	Retriever
+	// Retrieve resource and return resource content with its information
+	RetrieveWithInfo(ctx context.Context, resource *Resource) (*Result, error)
+}
+
+// Result is the content of a retrieved resource with information about where it came from.
+type Result struct {
+	Content []byte
+	Hash    Hash          // The hash of the commit the content was retrieved from.
+	Ref     string        // The name of the reference resolved to the commit (e.g. main or v0.1.0), if any.
+	RefType ReferenceType // The type of the reference resolved to the commit, e.g. branch, tag or hash.
+	Blob    Hash          // The hash of the blob of the file, if known.
+	Mode    fs.FileMode   // The mode of the file, if known.
+	Size    int64         // The size of the content in bytes.
+	Author  string        // The name of the author of the commit, if known.
+	Email   string        // The email address of the author of the commit, if known.
+	Time    time.Time     // The time the commit was authored, if known.
+	Remote  string        // The URL (or directory) of the repository the content was retrieved from.
+	Auth    string        // The name of the authentication method that fetched the content, or empty if it was cached.
+}
+
+// Reference returns the reference resolved to the commit the content was retrieved from.
+func (r *Result) Reference() *Reference {
+	return &Reference{name: r.Ref, hash: r.Hash, typ: r.RefType}
+}
+
+// RetrieveWithInfo retrieves the resource with the retriever, returning its content with information about where it
+// came from. Retrievers that don't implement InfoRetriever return only the information known from the resource.
+func RetrieveWithInfo(ctx context.Context, r Retriever, resource *Resource) (*Result, error) {
+	if ir, ok := r.(InfoRetriever); ok {
+		return ir.RetrieveWithInfo(ctx, resource)
+	}
+	resource = resource.Clone() // Retrievers that don't implement InfoRetriever may modify the reference.
+	content, err := r.Retrieve(ctx, resource)
+	if err != nil {
+		return nil, err
+	}
+	return NewResult(resource, content), nil
+}
+
+// NewResult returns the result of retrieving the content of the resource with the information known from the resource.
+func NewResult(resource *Resource, content []byte) *Result {
+	result := &Result{Content: content, Size: int64(len(content)), Remote: resource.Repo}
+	if resource.Ref != nil {
+		result.Hash = resource.Ref.Hash()
+		result.Ref = resource.Ref.Name()
+		result.RefType = resource.Ref.Type()
+	}
+	return result
+}
+
 // Resource represents git file resource.
+//
+// Retrievers don't modify the resources they retrieve, the reference resolved for a resource is returned by
+// RetrieveWithInfo instead.
 type Resource struct {
 	Repo     string
 	Filepath string
 	Ref      *Reference
+	Lines    LineRange // The lines of the file referred to, or the zero value for the entire file.
+}
+
+// Clone returns a copy of the resource (and its reference).
+func (r *Resource) Clone() *Resource {
+	c := *r
+	c.Ref = r.Ref.Clone()
+	return &c
+}
+
+// Relative returns the resource of the path relative to the directory of the file of the resource (or to the root of
+// its repository if the path begins with /), within the same repository at the same reference. Paths outside of the
+// repository are an error.
+func (r *Resource) Relative(rel string) (*Resource, error) {
+	if rel == "" {
+		return nil, fmt.Errorf("empty relative path of resource: %s", r)
+	}
+	p := path.Join(path.Dir(r.Filepath), rel)
+	if strings.HasPrefix(rel, "/") {
+		p = path.Join("/", rel)
+	}
+	p = strings.TrimPrefix(p, "/")
+	if p == "." {
+		p = "" // The root of the repository.
+	}
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return nil, fmt.Errorf("relative path %s is outside of the repository of resource: %s", rel, r)
+	}
+	return &Resource{Repo: r.Repo, Filepath: p, Ref: r.Ref.Clone()}, nil
 }
 
 // ParseResource takes a string in given format and returns the corresponding resource.
@@ -34,18 +128,9 @@ func ParseResource(str string, regexpStr string, repoidx, pathidx, refidx uint)
 
 	m := re.FindStringSubmatch(str)
 
-	ref := HEADReference()
-	if isHash(m[refidx]) {
-		h, err := NewHash(m[refidx])
-		if err != nil {
-			return nil, err
-		}
-		ref, err = NewHashReference(h)
-		if err != nil {
-			return nil, err
-		}
-	} else if m[refidx] != "" {
-		ref = NewSymbolicReference(m[refidx])
+	ref, err := ParseReference(m[refidx])
+	if err != nil {
+		return nil, err
 	}
 
 	return &Resource{
@@ -55,7 +140,42 @@ func ParseResource(str string, regexpStr string, repoidx, pathidx, refidx uint)
 	}, nil
 }
 
-// String returns resource representing in string.
+// ParseReference parses the reference as a hash, short hash (of at least 7 characters) or symbolic reference, or HEAD
+// if empty.
+func ParseReference(ref string) (*Reference, error) {
+	switch {
+	case isHash(ref):
+		h, err := NewHash(ref)
+		if err != nil {
+			return nil, err
+		}
+		return NewHashReference(h)
+	case isParsedShortHash(ref):
+		return NewShortHashReference(ref)
+	case ref != "":
+		return NewSymbolicReference(ref), nil
+	default:
+		return HEADReference(), nil
+	}
+}
+
+// parsedShortHashLen is the minimum length of a reference parsed as a short hash rather than a symbolic reference (git's
+// default abbreviation length), so that short branch or tag names made of hex characters (e.g. beef) aren't mistaken
+// for short hashes.
+const parsedShortHashLen = 7
+
+func isParsedShortHash(str string) bool {
+	return len(str) >= parsedShortHashLen && isShortHash(str)
+}
+
+// String returns the canonical URI of the resource, e.g. git+https://github.com/foo/bar//path/to/file@v0.0.1, which
+// ParseResourceURI parses back to the resource.
 func (r *Resource) String() string {
+	return r.uri()
+}
+
+// Path returns the resource as a path, e.g. github.com/foo/bar/path/to/file@v0.0.1, such as for storing a copy of its
+// file within a directory.
+func (r *Resource) Path() string {
 	return fmt.Sprintf("%s/%s@%s", r.Repo, r.Filepath, r.Ref.String())
 }
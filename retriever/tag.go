@@ -0,0 +1,51 @@
+package retriever
+
+import (
+	"context"
+	"fmt"
+)
+
+// TagResolver is the interface that wraps the ResolveRemoteTag method.
+// ResolveRemoteTag resolves the commit the tag of the remote repository currently refers to, returning false if the
+// remote repository has no such tag.
+type TagResolver interface {
+	ResolveRemoteTag(ctx context.Context, repo, tag string) (Hash, bool, error)
+}
+
+// TagPolicy describes how to handle moved (e.g. force-pushed) tags, i.e. tags whose commit advertised by the remote
+// repository differs from the commit they were known (cached or pinned) to refer to.
+type TagPolicy int
+
+const (
+	TagPolicyTrust  TagPolicy = iota // Assume tags never move, without checking the remote repository.
+	TagPolicyWarn                    // Log a warning, and keep the known commit.
+	TagPolicyFail                    // Fail with a *TagMovedError.
+	TagPolicyAccept                  // Accept (and update to) the new commit of the tag.
+)
+
+func (p TagPolicy) String() string {
+	switch p {
+	case TagPolicyTrust:
+		return "trust"
+	case TagPolicyWarn:
+		return "warn"
+	case TagPolicyFail:
+		return "fail"
+	case TagPolicyAccept:
+		return "accept"
+	default:
+		return "-"
+	}
+}
+
+// TagMovedError is returned when a tag of a repository refers to a different commit to that it was known to refer to.
+type TagMovedError struct {
+	Repo string
+	Tag  string
+	Old  Hash // The commit the tag was known to refer to.
+	New  Hash // The commit the remote repository advertises the tag refers to.
+}
+
+func (e *TagMovedError) Error() string {
+	return fmt.Sprintf("tag %s of repository %s moved from %s to %s", e.Tag, e.Repo, e.Old, e.New)
+}
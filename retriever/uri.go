@@ -0,0 +1,239 @@
+package retriever
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// URISchemeHTTPS is the scheme of the canonical URI of a resource within a remote repository.
+	URISchemeHTTPS = "git+https"
+	// URISchemeFile is the scheme of the canonical URI of a resource within a local repository (an absolute directory).
+	URISchemeFile = "git+file"
+
+	// uriRepoSeparator separates the repository from the path of the file within it.
+	uriRepoSeparator = "//"
+)
+
+// LineRange is a range of lines of a file, from Start to End inclusive (counting from 1). The zero value is the entire
+// file.
+type LineRange struct {
+	Start int
+	End   int
+}
+
+// IsZero reports whether the range is the entire file.
+func (l LineRange) IsZero() bool {
+	return l == LineRange{}
+}
+
+// String returns the range as a fragment of a URI (without #), e.g. L10 or L10-L20, or empty if it's the entire file.
+func (l LineRange) String() string {
+	switch {
+	case l.IsZero():
+		return ""
+	case l.End == l.Start:
+		return fmt.Sprintf("L%d", l.Start)
+	default:
+		return fmt.Sprintf("L%d-L%d", l.Start, l.End)
+	}
+}
+
+// Extract returns the lines of the content within the range (ignoring lines beyond the end of the content), or all
+// of the content if the range is the entire file.
+func (l LineRange) Extract(content []byte) []byte {
+	if l.IsZero() {
+		return content
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	start, end := min(l.Start-1, len(lines)), min(l.End, len(lines))
+	return []byte(strings.Join(lines[start:end], ""))
+}
+
+var (
+	// uriHostRegexp matches the host (and optional port) of a remote repository, e.g. gitlab.example.com:8443.
+	uriHostRegexp = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*(:[0-9]+)?$`)
+	// uriLinesRegexp matches a fragment of lines, e.g. L10 or L10-L20.
+	uriLinesRegexp = regexp.MustCompile(`^L([1-9][0-9]*)(-L([1-9][0-9]*))?$`)
+)
+
+// IsResourceURI reports whether the string is (or appears to be) the canonical URI of a resource.
+func IsResourceURI(str string) bool {
+	return strings.HasPrefix(str, URISchemeHTTPS+"://") || strings.HasPrefix(str, URISchemeFile+"://")
+}
+
+// ParseResourceURI parses the canonical URI of a resource, of the form:
+//
+//	git+https://host[:port]/group/[subgroup/...]repo//path/to/file[@ref][#L10[-L20]]
+//	git+file:///path/to/repo//path/to/file[@ref][#L10[-L20]]
+//
+// The repository is separated from the path of the file by //, so repositories may be nested within any number of
+// groups (e.g. GitLab subgroups). The reference is parsed as by ParseReference, and is HEAD if omitted. The fragment
+// selects a line, or an inclusive range of lines, of the file. Any %, @ and # in the repository or the path of the file
+// are percent-escaped (e.g. node_modules/%40types/node/index.d.ts), so that they aren't taken for the reference or the
+// fragment.
+//
+// Canonical URIs round-trip exactly, i.e. ParseResourceURI(uri).String() == uri. The only non-canonical forms
+// accepted are an explicit @HEAD, which is omitted, and a short hash in upper case, which is lower-cased.
+func ParseResourceURI(uri string) (*Resource, error) {
+	scheme, rest, ok := strings.Cut(uri, "://")
+	if !ok || (scheme != URISchemeHTTPS && scheme != URISchemeFile) {
+		return nil, fmt.Errorf("%s is not a resource URI: scheme must be %s or %s", uri, URISchemeHTTPS, URISchemeFile)
+	}
+
+	rest, fragment, hasFragment := strings.Cut(rest, "#")
+	repo, file, ok := strings.Cut(rest, uriRepoSeparator)
+	if scheme == URISchemeFile {
+		// The repository is an absolute directory, so the separator is the first // after its leading /.
+		repo, file, ok = strings.Cut(strings.TrimPrefix(rest, "/"), uriRepoSeparator)
+		repo = "/" + repo
+	}
+	if !ok {
+		return nil, fmt.Errorf("%s is not a resource URI: repository and file must be separated by %s", uri, uriRepoSeparator)
+	}
+	// References can't contain //, so are split from the path of the file (which has any @ escaped).
+	file, ref, hasRef := strings.Cut(file, "@")
+
+	var err error
+	if repo, err = unescapeURIPath(repo); err != nil {
+		return nil, fmt.Errorf("%s is not a resource URI: invalid repository %q: %w", uri, repo, err)
+	}
+	if file, err = unescapeURIPath(file); err != nil {
+		return nil, fmt.Errorf("%s is not a resource URI: invalid file path %q: %w", uri, file, err)
+	}
+
+	if err := validateURIRepo(scheme, repo); err != nil {
+		return nil, fmt.Errorf("%s is not a resource URI: %w", uri, err)
+	}
+	if err := validateURIPath(file, true); err != nil {
+		return nil, fmt.Errorf("%s is not a resource URI: invalid file path %q: %w", uri, file, err)
+	}
+
+	resource := &Resource{Repo: repo, Filepath: file, Ref: HEADReference()}
+	if hasRef {
+		if ref == "" {
+			return nil, fmt.Errorf("%s is not a resource URI: empty reference", uri)
+		}
+		r, err := ParseReference(ref)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a resource URI: %w", uri, err)
+		}
+		resource.Ref = r
+	}
+	if hasFragment {
+		lines, err := parseLineRange(fragment)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a resource URI: %w", uri, err)
+		}
+		resource.Lines = lines
+	}
+	return resource, nil
+}
+
+// validateURIRepo checks the repository of a URI is a host followed by a path (or an absolute directory for local
+// repositories).
+func validateURIRepo(scheme, repo string) error {
+	if scheme == URISchemeFile {
+		if err := validateURIPath(strings.TrimPrefix(repo, "/"), false); err != nil {
+			return fmt.Errorf("invalid repository directory %q: %w", repo, err)
+		}
+		return nil
+	}
+	host, p, _ := strings.Cut(repo, "/")
+	if !uriHostRegexp.MatchString(host) {
+		return fmt.Errorf("invalid repository host %q", host)
+	}
+	if err := validateURIPath(p, false); err != nil {
+		return fmt.Errorf("invalid repository path %q: %w", p, err)
+	}
+	return nil
+}
+
+// validateURIPath checks the path is made of non-empty segments (other than . and ..), or is empty if allowed.
+func validateURIPath(p string, allowEmpty bool) error {
+	if p == "" {
+		if allowEmpty {
+			return nil
+		}
+		return fmt.Errorf("path must not be empty")
+	}
+	for _, s := range strings.Split(p, "/") {
+		switch {
+		case s == "":
+			return fmt.Errorf("path must not contain empty segments")
+		case s == "." || s == "..":
+			return fmt.Errorf("path must not contain %s segments", s)
+		case strings.ContainsAny(s, " \t\n\\"):
+			return fmt.Errorf("path must not contain whitespace or backslashes")
+		}
+	}
+	return nil
+}
+
+// uriPathEscaper escapes the characters of paths that delimit the parts of a URI (and the escape character itself).
+var uriPathEscaper = strings.NewReplacer("%", "%25", "@", "%40", "#", "%23")
+
+// escapeURIPath escapes the path for a URI, i.e. any %, @ and #.
+func escapeURIPath(p string) string {
+	return uriPathEscaper.Replace(p)
+}
+
+// unescapeURIPath unescapes the path of a URI, which must be escaped exactly as by escapeURIPath.
+func unescapeURIPath(p string) (string, error) {
+	if !strings.Contains(p, "%") {
+		return p, nil
+	}
+	unescaped, err := url.PathUnescape(p)
+	if err != nil {
+		return "", err
+	}
+	if escapeURIPath(unescaped) != p {
+		return "", fmt.Errorf("only %%, @ and # may be escaped, as %%25, %%40 and %%23")
+	}
+	return unescaped, nil
+}
+
+// parseLineRange parses a fragment of lines, e.g. L10 or L10-L20.
+func parseLineRange(fragment string) (LineRange, error) {
+	m := uriLinesRegexp.FindStringSubmatch(fragment)
+	if m == nil {
+		return LineRange{}, fmt.Errorf("invalid fragment %q: must be a line (e.g. L10) or a range of lines (e.g. L10-L20)", fragment)
+	}
+	start, err := strconv.Atoi(m[1])
+	if err != nil {
+		return LineRange{}, fmt.Errorf("invalid fragment %q: %w", fragment, err)
+	}
+	end := start
+	if m[3] != "" {
+		if end, err = strconv.Atoi(m[3]); err != nil {
+			return LineRange{}, fmt.Errorf("invalid fragment %q: %w", fragment, err)
+		}
+		if end <= start {
+			return LineRange{}, fmt.Errorf("invalid fragment %q: end of range must be after its start", fragment)
+		}
+	}
+	return LineRange{Start: start, End: end}, nil
+}
+
+// uri returns the canonical URI of the resource (see ParseResourceURI).
+func (r *Resource) uri() string {
+	scheme := URISchemeHTTPS
+	if strings.HasPrefix(r.Repo, "/") {
+		scheme = URISchemeFile
+	}
+	var b strings.Builder
+	b.WriteString(scheme + "://" + escapeURIPath(r.Repo) + uriRepoSeparator + escapeURIPath(r.Filepath))
+	if r.Ref != nil && !(r.Ref.IsHEAD() && !r.Ref.IsHash()) {
+		b.WriteString("@" + r.Ref.String())
+	}
+	if !r.Lines.IsZero() {
+		b.WriteString("#" + r.Lines.String())
+	}
+	return b.String()
+}
@@ -0,0 +1,114 @@
+package retriever
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseResourceURI(t *testing.T) {
+	h, err := NewHash("1e7c4cecaaa8f76e3c668cebc411f1b03171501f")
+	require.NoError(t, err)
+	hash, err := NewHashReference(h)
+	require.NoError(t, err)
+	shortHash, err := NewShortHashReference("1e7c4ce")
+	require.NoError(t, err)
+
+	for uri, resource := range map[string]*Resource{
+		"git+https://github.com/foo/bar//README.md": {Repo: "github.com/foo/bar", Filepath: "README.md", Ref: HEADReference()},
+		"git+https://github.com/foo/bar//":          {Repo: "github.com/foo/bar", Ref: HEADReference()},
+		"git+https://gitlab.com/group/sub/repo//api/spec.yaml@v1.0.0": {
+			Repo: "gitlab.com/group/sub/repo", Filepath: "api/spec.yaml", Ref: NewSymbolicReference("v1.0.0")},
+		"git+https://git.example.com:8443/repo//file@feature/foo-bar.baz": {
+			Repo: "git.example.com:8443/repo", Filepath: "file", Ref: NewSymbolicReference("feature/foo-bar.baz")},
+		"git+https://github.com/foo/bar//file@1e7c4cecaaa8f76e3c668cebc411f1b03171501f#L10-L20": {
+			Repo: "github.com/foo/bar", Filepath: "file", Ref: hash, Lines: LineRange{Start: 10, End: 20}},
+		"git+https://github.com/foo/bar//file@1e7c4ce#L7": {
+			Repo: "github.com/foo/bar", Filepath: "file", Ref: shortHash, Lines: LineRange{Start: 7, End: 7}},
+		"git+file:///tmp/repo//dir/file@main": {Repo: "/tmp/repo", Filepath: "dir/file", Ref: NewSymbolicReference("main")},
+		"git+https://github.com/foo/bar//%40types/index.d.ts": {
+			Repo: "github.com/foo/bar", Filepath: "@types/index.d.ts", Ref: HEADReference()},
+		"git+https://github.com/foo/bar//node_modules/%40scope/x@main": {
+			Repo: "github.com/foo/bar", Filepath: "node_modules/@scope/x", Ref: NewSymbolicReference("main")},
+		"git+https://github.com/foo/bar//a%2540b/c%23d#L1": {
+			Repo: "github.com/foo/bar", Filepath: "a%40b/c#d", Ref: HEADReference(), Lines: LineRange{Start: 1, End: 1}},
+		"git+file:///tmp/%40repo//file@feature@2": {Repo: "/tmp/@repo", Filepath: "file", Ref: NewSymbolicReference("feature@2")},
+	} {
+		t.Run(uri, func(t *testing.T) {
+			require.True(t, IsResourceURI(uri))
+			r, err := ParseResourceURI(uri)
+			require.NoError(t, err)
+			require.Equal(t, resource, r)
+			require.Equal(t, uri, r.String())
+		})
+	}
+
+	r, err := ParseResourceURI("git+https://github.com/foo/bar//README.md@HEAD")
+	require.NoError(t, err)
+	require.True(t, r.Ref.IsHEAD())
+	require.Equal(t, "git+https://github.com/foo/bar//README.md", r.String())
+
+	for _, invalid := range []string{
+		"github.com/foo/bar//README.md",
+		"https://github.com/foo/bar//README.md",
+		"git+https://github.com/foo/bar/README.md",
+		"git+https:////README.md",
+		"git+https://github.com//README.md",
+		"git+https://-github.com/foo//README.md",
+		"git+https://github.com:port/foo//README.md",
+		"git+https://github.com/foo/../bar//README.md",
+		"git+https://github.com/foo/bar//dir//README.md",
+		"git+https://github.com/foo/bar//README.md@",
+		"git+https://github.com/foo/bar//README.md#10",
+		"git+https://github.com/foo/bar//README.md#L0",
+		"git+https://github.com/foo/bar//README.md#L20-L10",
+		"git+https://github.com/foo/bar//README.md#L10-L10",
+		"git+file://tmp/repo",
+		"git+https://github.com/foo/bar//%20file",
+		"git+https://github.com/foo/bar//%4",
+		"git+https://github.com/foo/bar//%2e%2e/file",
+	} {
+		_, err := ParseResourceURI(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestLineRangeExtract(t *testing.T) {
+	content := []byte("one\ntwo\nthree\n")
+	require.Equal(t, content, LineRange{}.Extract(content))
+	require.Equal(t, "two\n", string(LineRange{Start: 2, End: 2}.Extract(content)))
+	require.Equal(t, "two\nthree\n", string(LineRange{Start: 2, End: 10}.Extract(content)))
+	require.Empty(t, LineRange{Start: 5, End: 6}.Extract(content))
+	require.Equal(t, "two", string(LineRange{Start: 2, End: 3}.Extract([]byte("one\ntwo"))))
+}
+
+func TestResourcePath(t *testing.T) {
+	r := &Resource{Repo: "github.com/foo/bar", Filepath: "path/to/file", Ref: NewSymbolicReference("v0.0.1")}
+	require.Equal(t, "github.com/foo/bar/path/to/file@v0.0.1", r.Path())
+	require.Equal(t, "git+https://github.com/foo/bar//path/to/file@v0.0.1", r.String())
+}
+
+func TestResourceRelative(t *testing.T) {
+	r := &Resource{Repo: "github.com/org/specs", Filepath: "a/b.yaml", Ref: NewSymbolicReference("v1"), Lines: LineRange{Start: 1, End: 2}}
+	for rel, p := range map[string]string{
+		"c.yaml":               "a/c.yaml",
+		"./c/d.yaml":           "a/c/d.yaml",
+		"../common/types.yaml": "common/types.yaml",
+		"/common/types.yaml":   "common/types.yaml",
+		".":                    "a",
+		"..":                   "",
+		"/":                    "",
+	} {
+		t.Run(rel, func(t *testing.T) {
+			resolved, err := r.Relative(rel)
+			require.NoError(t, err)
+			require.Equal(t, &Resource{Repo: r.Repo, Filepath: p, Ref: r.Ref}, resolved)
+			require.NotSame(t, r.Ref, resolved.Ref)
+		})
+	}
+
+	for _, invalid := range []string{"", "../..", "../../types.yaml"} {
+		_, err := r.Relative(invalid)
+		require.Error(t, err, invalid)
+	}
+}